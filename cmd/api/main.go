@@ -30,7 +30,9 @@ func main() {
 
 		// Start the API server
 		server := api.NewServer(cfg)
-		log.Fatal(server.Start(*port))
+		if err := server.Start(*port); err != nil {
+			log.Fatal(err)
+		}
 		return
 	}
 
@@ -42,29 +44,68 @@ func main() {
 
 	// Start the API server
 	server := api.NewServer(cfg)
-	log.Fatal(server.Start(*port))
+	if err := server.Start(*port); err != nil {
+		log.Fatal(err)
+	}
 }
 
 // createDefaultConfig creates a default configuration for the API server
 func createDefaultConfig() *config.Config {
 	return &config.Config{
-		InputFile:         "input.csv",
-		OutputFile:        "output.csv",
-		InputType:         "csv",
-		OutputType:        "csv",
-		ValidThreshold:    80,
-		RiskyThreshold:    60,
-		DefaultRiskyScore: 50,
-		MaxRetries:        3,
-		InitialBackoff:    time.Second,
-		NumWorkers:        10,
+		InputFile:               "input.csv",
+		OutputFile:              "output.csv",
+		InputType:               "csv",
+		OutputType:              "csv",
+		ValidThreshold:          80,
+		RiskyThreshold:          60,
+		DefaultRiskyScore:       50,
+		MaxRetries:              3,
+		InitialBackoff:          time.Second,
+		AttemptTimeout:          30 * time.Second,
+		VerifyTimeout:           2 * time.Minute,
+		NumWorkers:              10,
+		ShutdownTimeout:         30 * time.Second,
+		SMTPPort:                25,
+		SMTPTimeout:             10 * time.Second,
+		HelloName:               "localhost",
+		FromEmail:               "user@example.org",
+		MaxRecipientsPerSession: 20,
+		RateLimits: config.RateLimits{
+			Global:    10,
+			PerDomain: 2,
+			PerMX:     5,
+			Burst:     1,
+		},
+		GreylistDelay:   5 * time.Minute,
+		GreylistRetries: 1,
+		Cache: config.CacheConfig{
+			Type: "memory",
+			Size: 10000,
+			TTL: config.CacheTTLs{
+				Valid:   30 * 24 * time.Hour,
+				Risky:   7 * 24 * time.Hour,
+				Invalid: 7 * 24 * time.Hour,
+				Unknown: 24 * time.Hour,
+			},
+		},
+		DomainCacheTTL: time.Hour,
 		ScoringWeights: config.ScoringWeights{
 			HasMxRecords:     20,
+			ImplicitMX:       15,
 			ReachableYes:     40,
 			ReachableUnknown: 20,
 			RoleAccount:      -10,
 			FreeProvider:     -5,
 			Suggestion:       -10,
+			CatchAll:         -20,
+			NoSPF:            -10,
+			NoDMARC:          -10,
+
+			PersonalEmailForBusinessLead: -15,
+			DomainMismatch:               -10,
+			NameMatch:                    10,
+			NameMismatch:                 -15,
+			LearnedPatternMatch:          15,
 		},
 	}
 }
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/clau/email_verifier/pkg/config"
+	"github.com/clau/email_verifier/pkg/io"
+	"github.com/clau/email_verifier/pkg/verifier"
+)
+
+// runFind is the find subcommand: it looks for the addresses of leads from
+// their name and company domain. Given -domain it looks up one person and
+// prints the outcome; otherwise it looks up every row of the input file
+// whose email is blank and writes the file with what it found.
+func runFind(args []string) {
+	flags := flag.NewFlagSet("find", flag.ExitOnError)
+	firstName := flags.String("first", "", "First name of the person to look up")
+	lastName := flags.String("last", "", "Last name of the person to look up")
+	domain := flags.String("domain", "", "Company domain or website of the person to look up")
+	flags.Parse(args)
+
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	// Ctrl-C or SIGTERM abandons the lookups in progress
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := config.LoadConfig("config.yaml")
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	v := verifier.New(cfg)
+
+	if *domain != "" {
+		lead := verifier.Lead{CompanyDomain: *domain, FirstName: *firstName, LastName: *lastName}
+		find, err := v.Find(ctx, lead)
+		if err != nil {
+			log.Fatalf("Error finding email: %v", err)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(find)
+		return
+	}
+
+	findRecords(ctx, cfg, v)
+}
+
+// findRecords looks up the address of every row of the input file whose
+// email is blank, using the lead columns for the name and company domain,
+// and writes the rows with the outcome
+func findRecords(ctx context.Context, cfg *config.Config, v *verifier.Verifier) {
+	columns := cfg.LeadColumns
+	if columns.CompanyDomain == "" || (columns.FirstName == "" && columns.LastName == "") {
+		log.Fatalf("Finding emails needs lead_columns.company_domain and first_name or last_name in config.yaml")
+	}
+
+	inputFile, err := findFile(cfg.InputFile)
+	if err != nil {
+		log.Fatalf("Error finding input file: %v", err)
+	}
+	schema, records, err := io.ReadRecords(inputFile, cfg.InputType)
+	if err != nil {
+		log.Fatalf("Error reading input file: %v", err)
+	}
+	for _, column := range []string{columns.CompanyDomain, columns.FirstName, columns.LastName} {
+		if column != "" && !schema.Has(column) {
+			log.Fatalf("Lead column %q not found in input headers", column)
+		}
+	}
+
+	// Rows with an address already are left alone. Without an email column,
+	// every row is looked up.
+	emailColumn := -1
+	if emailColumns, err := schema.EmailColumns(cfg.EmailColumns, records[:min(len(records), emailSampleSize)]); err == nil {
+		emailColumn = emailColumns[0]
+	}
+	var pending []int
+	for i, record := range records {
+		if emailColumn < 0 || strings.TrimSpace(record.Values[emailColumn]) == "" {
+			pending = append(pending, i)
+		}
+	}
+	fmt.Printf("Looking for %d addresses\n", len(pending))
+
+	// Look up the rows on a pool of workers
+	start := time.Now()
+	results := make([]*verifier.FindResult, len(records))
+	rows := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < max(cfg.NumWorkers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range rows {
+				results[i] = findRecord(ctx, v, records[i], columns)
+			}
+		}()
+	}
+	for _, i := range pending {
+		rows <- i
+	}
+	close(rows)
+	wg.Wait()
+
+	found := 0
+	for _, result := range results {
+		if result != nil && result.Status == "found" {
+			found++
+		}
+	}
+
+	if err := io.WriteFindResults(cfg.OutputFile, cfg.OutputType, schema, records, results); err != nil {
+		log.Fatalf("Error writing results: %v", err)
+	}
+	fmt.Printf("\nFound %d of %d addresses in %v\n", found, len(pending), time.Since(start))
+	fmt.Printf("Results saved to %s\n", cfg.OutputFile)
+}
+
+// findRecord looks up the address of the lead in a record. Rows lacking a
+// name or domain are reported as incomplete; failed lookups keep the
+// addresses tried with the status "error", or "not_checked" once
+// interrupted.
+func findRecord(ctx context.Context, v *verifier.Verifier, record io.Record, columns config.LeadColumns) *verifier.FindResult {
+	lead := verifier.Lead{CompanyDomain: record.Get(columns.CompanyDomain)}
+	if columns.FirstName != "" {
+		lead.FirstName = record.Get(columns.FirstName)
+	}
+	if columns.LastName != "" {
+		lead.LastName = record.Get(columns.LastName)
+	}
+
+	find, err := v.Find(ctx, lead)
+	switch {
+	case errors.Is(err, verifier.ErrIncompleteLead):
+		return &verifier.FindResult{Status: "incomplete"}
+	case verifier.ErrorCategoryOf(err) == verifier.CategoryCanceled:
+		find.Status = "not_checked"
+	case err != nil:
+		log.Printf("Error finding email for %s %s at %s: %v", lead.FirstName, lead.LastName, lead.CompanyDomain, err)
+		find.Status = "error"
+	}
+	return find
+}
@@ -4,13 +4,13 @@ go 1.24.0
 
 require (
 	github.com/AfterShip/email-verifier v1.4.1
+	github.com/gorilla/mux v1.8.1
 	github.com/tealeg/xlsx v1.0.5
+	golang.org/x/net v0.29.0
+	golang.org/x/text v0.18.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/gorilla/mux v1.8.1 // indirect
 	github.com/hbollon/go-edlib v1.6.0 // indirect
-	golang.org/x/net v0.29.0 // indirect
-	golang.org/x/text v0.18.0 // indirect
 )
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"github.com/clau/email_verifier/pkg/cache"
 	"github.com/clau/email_verifier/pkg/config"
 	"github.com/clau/email_verifier/pkg/io"
 	"github.com/clau/email_verifier/pkg/verifier"
@@ -26,6 +31,14 @@ type progress struct {
 	lastUpdate time.Time
 }
 
+func newProgress(total int) *progress {
+	return &progress{
+		total:      total,
+		startTime:  time.Now(),
+		lastUpdate: time.Now(),
+	}
+}
+
 func (p *progress) update(status string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -46,15 +59,84 @@ func (p *progress) update(status string) {
 	if time.Since(p.lastUpdate) >= time.Second {
 		elapsed := time.Since(p.startTime)
 		rate := float64(p.processed) / elapsed.Seconds()
-		fmt.Printf("\rProgress: %d/%d (%.1f%%), Rate: %.1f/s, Valid: %d, Risky: %d, Invalid: %d, Errors: %d",
-			p.processed, p.total,
-			float64(p.processed)*100/float64(p.total),
-			rate,
-			p.valid, p.risky, p.invalid, p.errors)
+		if p.total > 0 {
+			fmt.Printf("\rProgress: %d/%d (%.1f%%), Rate: %.1f/s, Valid: %d, Risky: %d, Invalid: %d, Errors: %d",
+				p.processed, p.total,
+				float64(p.processed)*100/float64(p.total),
+				rate,
+				p.valid, p.risky, p.invalid, p.errors)
+		} else {
+			// Streaming: the total is not known up front
+			fmt.Printf("\rProgress: %d, Rate: %.1f/s, Valid: %d, Risky: %d, Invalid: %d, Errors: %d",
+				p.processed, rate,
+				p.valid, p.risky, p.invalid, p.errors)
+		}
 		p.lastUpdate = time.Now()
 	}
 }
 
+// revise moves a re-verified email from its old status count to its new one
+func (p *progress) revise(oldStatus, newStatus string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for status, delta := range map[string]int{oldStatus: -1, newStatus: 1} {
+		switch status {
+		case "valid":
+			p.valid += delta
+		case "risky":
+			p.risky += delta
+		case "invalid":
+			p.invalid += delta
+		}
+	}
+}
+
+// emailSampleSize is how many rows are sampled to detect the email columns
+// when none are configured
+const emailSampleSize = 100
+
+// parseColumns splits a comma-separated list of column names
+func parseColumns(list string) []string {
+	var columns []string
+	for _, column := range strings.Split(list, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// notCheckedResult is the result of a row an interruption left unverified
+func notCheckedResult(email string) verifier.Result {
+	result := verifier.NewResult(email)
+	result.VerificationStatus = "not_checked"
+	return result
+}
+
+// shareResult returns the result of an address for another occurrence of it
+// or one of its aliases, reported as written there
+func shareResult(result verifier.Result, email string) verifier.Result {
+	if result.Email == "" {
+		return result
+	}
+	return result.As(email)
+}
+
+// fileRow returns the row number of a data row in the input file, counting
+// the header as row 1
+func fileRow(index int) int {
+	return index + 2
+}
+
+// progressStatus returns the status a result is counted under in the progress
+func progressStatus(result verifier.Result) string {
+	if result.ErrorCategory != "" {
+		return "error"
+	}
+	return result.VerificationStatus
+}
+
 // findFile attempts to find a file with case-insensitive matching
 func findFile(filename string) (string, error) {
 	// First, try the exact filename
@@ -86,9 +168,40 @@ func findFile(filename string) (string, error) {
 }
 
 func main() {
+	// Find mode looks up missing addresses instead of verifying them
+	if len(os.Args) > 1 && os.Args[1] == "find" {
+		runFind(os.Args[2:])
+		return
+	}
+
+	// Parse command line flags
+	skipDeferred := flag.Bool("skip-deferred", false, "Write results without re-verifying greylisted addresses")
+	noCache := flag.Bool("no-cache", false, "Verify every email instead of reusing cached results")
+	resume := flag.Bool("resume", false, "Continue an interrupted run, skipping rows recorded in the journal")
+	stream := flag.Bool("stream", false, "Read and write CSV files a row at a time to handle very large files in bounded memory")
+	emailColumns := flag.String("email-columns", "", "Comma-separated names of the columns holding the emails to verify (overrides email_columns)")
+	dropDuplicates := flag.Bool("drop-duplicates", false, "Leave rows repeating an earlier row's email out of the output")
+	flag.Parse()
+
 	// Configure logging
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
+	// The first Ctrl-C or SIGTERM stops starting new batches and lets the
+	// workers finish theirs; a second one abandons those as well
+	feedCtx, stopFeeding := context.WithCancel(context.Background())
+	defer stopFeeding()
+	ctx, abort := context.WithCancel(context.Background())
+	defer abort()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		fmt.Println("\n\nStopping: finishing in-flight batches, interrupt again to abandon them...")
+		stopFeeding()
+		<-signals
+		abort()
+	}()
+
 	// Load configuration
 	cfg, err := config.LoadConfig("config.yaml")
 	if err != nil {
@@ -103,9 +216,113 @@ func main() {
 
 	// Update config with the actual file path
 	cfg.InputFile = inputFile
+	if columns := parseColumns(*emailColumns); len(columns) > 0 {
+		cfg.EmailColumns = columns
+	}
+	if *dropDuplicates {
+		cfg.DropDuplicates = true
+	}
+
+	// Initialize verifier and result cache
+	v := verifier.New(cfg)
+	resultCache, err := cache.New(cfg.Cache)
+	if err != nil {
+		log.Fatalf("Error opening cache: %v", err)
+	}
+	defer func() {
+		if err := resultCache.Close(); err != nil {
+			log.Printf("Error closing cache: %v", err)
+		}
+	}()
+
+	// Reload the rows finished by an interrupted run, then keep journaling
+	// results as they come in
+	journalPath := cfg.JournalFile
+	if journalPath == "" {
+		journalPath = cfg.OutputFile + ".journal"
+	}
+	resumed := make(map[io.Cell]verifier.Result)
+	var resumedIndex *io.JournalIndex
+	if *resume {
+		// A streamed run reads each result back when its row comes up, so
+		// that only the journal's index is held in memory
+		if *stream {
+			resumedIndex, err = io.IndexJournal(journalPath)
+		} else {
+			resumed, err = io.LoadJournal(journalPath)
+		}
+		if err != nil {
+			log.Fatalf("Error loading journal: %v", err)
+		}
+		defer resumedIndex.Close()
+		fmt.Printf("Resuming with %d rows already verified\n", len(resumed)+resumedIndex.Len())
+	}
+	journal, err := io.OpenJournal(journalPath, *resume)
+	if err != nil {
+		log.Fatalf("Error opening journal: %v", err)
+	}
+
+	// Verify the rows and write the results, parking greylisted addresses
+	// for a deferred pass
+	p := &pipeline{
+		verifier:        v,
+		cache:           resultCache,
+		bypassCache:     *noCache,
+		deferred:        verifier.NewDeferredQueue(cfg.GreylistDelay),
+		greylistRetries: cfg.GreylistRetries,
+		journal:         journal,
+		leadColumns:     cfg.LeadColumns,
+		stop:            feedCtx,
+		numWorkers:      cfg.NumWorkers,
+	}
+	if *skipDeferred {
+		p.greylistRetries = 0
+	}
+	if cfg.LeadColumns.FirstName != "" || cfg.LeadColumns.LastName != "" {
+		p.patterns = verifier.NewPatternLearner(cfg.PatternLearning)
+	}
+	var progress *progress
+	var notChecked int
+	if *stream {
+		progress, notChecked = p.processStream(ctx, cfg, resumedIndex)
+	} else {
+		progress, notChecked = p.processRecords(ctx, cfg, resumed)
+	}
+	interrupted := p.stopped()
+
+	// Keep the journal of an interrupted run so it can be resumed; a complete
+	// run leaves nothing to resume
+	if interrupted {
+		journal.Close()
+	} else if err := journal.Remove(); err != nil {
+		log.Printf("Error removing journal: %v", err)
+	}
+
+	// Print final statistics
+	elapsed := time.Since(progress.startTime)
+	fmt.Printf("\n\nVerification completed in %v\n", elapsed)
+	fmt.Printf("Total processed: %d\n", progress.processed)
+	fmt.Printf("Valid: %d (%.1f%%)\n", progress.valid, float64(progress.valid)*100/float64(progress.processed))
+	fmt.Printf("Risky: %d (%.1f%%)\n", progress.risky, float64(progress.risky)*100/float64(progress.processed))
+	fmt.Printf("Invalid: %d (%.1f%%)\n", progress.invalid, float64(progress.invalid)*100/float64(progress.processed))
+	fmt.Printf("Errors: %d (%.1f%%)\n", progress.errors, float64(progress.errors)*100/float64(progress.processed))
+	if hits, misses := v.DomainCacheStats(); hits+misses > 0 {
+		fmt.Printf("Domain cache: %d hits, %d lookups (%.1f%% hit rate)\n", hits, misses, float64(hits)*100/float64(hits+misses))
+	}
+	fmt.Printf("Results saved to %s\n", cfg.OutputFile)
+	p.writePatternReport(cfg)
+	if interrupted {
+		fmt.Printf("Verification interrupted: %d addresses were not checked. Run again with -resume to finish them.\n", notChecked)
+	}
+}
 
+// processRecords reads the whole input file, verifies the addresses in its
+// email columns, re-verifies greylisted addresses and writes the output. It
+// returns the progress and the number of addresses an interruption left
+// unverified.
+func (p *pipeline) processRecords(ctx context.Context, cfg *config.Config, resumed map[io.Cell]verifier.Result) (*progress, int) {
 	// Read input records
-	records, err := io.ReadRecords(cfg.InputFile, cfg.InputType)
+	schema, records, err := io.ReadRecords(cfg.InputFile, cfg.InputType)
 	if err != nil {
 		log.Fatalf("Error reading input file: %v", err)
 	}
@@ -114,143 +331,376 @@ func main() {
 		log.Fatalf("No records found in input file: %s", cfg.InputFile)
 	}
 
-	// Validate that records have email field (case-insensitive)
+	// Find the columns holding the emails to verify
+	columns, err := schema.EmailColumns(cfg.EmailColumns, records[:min(len(records), emailSampleSize)])
+	if err != nil {
+		log.Fatalf("Error finding email columns in %s: %v", cfg.InputFile, err)
+	}
+	p.checkLeadColumns(schema)
+
+	// Initialize progress tracking
+	progress := newProgress(0)
+
+	// Collect the addresses to verify, skipping those finished before
+	// resuming. Greylisted addresses are parked again for the deferred pass.
+	// Each address is verified once, at its first occurrence; the others
+	// reuse that result.
+	results := make(map[io.Cell]verifier.Result)
+	first := make(map[string]task) // normalized email -> first occurrence
+	var duplicates []task          // later occurrences
+	firstRows := newFirstRows(0)   // no limit, as the whole file is in memory
+	duplicateOf := make([]int, len(records))
+	tasks := make([]task, 0, len(records)*len(columns))
 	for i, record := range records {
-		hasEmail := false
-		for key := range record {
-			if strings.EqualFold(key, "email") {
-				hasEmail = true
-				break
+		row := io.Row{Index: i, Record: record}
+		for k, column := range columns {
+			t := task{row: row, column: column, email: strings.TrimSpace(record.Values[column])}
+			if t.email == "" {
+				if k == 0 {
+					log.Printf("Warning: No email in row %d. Setting to invalid.", i)
+					results[t.cell()] = verifier.Result{VerificationStatus: "invalid"}
+				}
+				continue
+			}
+
+			if k == 0 {
+				duplicateOf[i] = firstRows.duplicateOf(t)
 			}
+			key := verifier.NormalizeEmail(t.email)
+			if _, ok := first[key]; ok {
+				duplicates = append(duplicates, t)
+				continue
+			}
+			first[key] = t
+
+			progress.total++
+			if result, ok := resumed[t.cell()]; ok && strings.EqualFold(result.Email, t.email) {
+				results[t.cell()] = result
+				progress.update(progressStatus(result))
+				if result.Checks != nil && result.Checks.Greylisted {
+					p.deferred.Add(t.email)
+				}
+				continue
+			}
+			tasks = append(tasks, t)
 		}
-		if !hasEmail {
-			log.Fatalf("Record at index %d is missing the required 'email' field", i)
+	}
+	for cell, result := range p.verify(ctx, tasks, progress) {
+		results[cell] = result
+	}
+
+	// Re-verify greylisted addresses once the greylisting delay has passed
+	for pass := 0; pass < p.greylistRetries && p.deferred.Len() > 0 && !p.stopped(); pass++ {
+		fmt.Printf("\n\nWaiting %v to re-verify %d greylisted addresses...\n", p.deferred.Delay(), p.deferred.Len())
+		retryEmails, err := p.deferred.Drain(p.stop)
+		if err != nil {
+			break
+		}
+
+		var retry []task
+		seen := make(map[string]bool)
+		for _, email := range retryEmails {
+			key := verifier.NormalizeEmail(email)
+			if t, ok := first[key]; ok && !seen[key] {
+				seen[key] = true
+				retry = append(retry, t)
+			}
+		}
+		for cell, result := range p.verify(ctx, retry, newProgress(len(retry))) {
+			// Keep the greylisted result of an abandoned retry
+			if result.VerificationStatus == "not_checked" {
+				continue
+			}
+			progress.revise(results[cell].VerificationStatus, result.VerificationStatus)
+			results[cell] = result
 		}
 	}
+	if n := p.deferred.Len(); n > 0 {
+		fmt.Printf("\n\n%d greylisted addresses were not re-verified\n", n)
+	}
 
-	// Initialize progress tracking
-	progress := &progress{
-		total:      len(records),
-		startTime:  time.Now(),
-		lastUpdate: time.Now(),
+	// Give repeated addresses the result of their first occurrence
+	for _, t := range duplicates {
+		if result, ok := results[first[verifier.NormalizeEmail(t.email)].cell()]; ok {
+			results[t.cell()] = shareResult(result, t.email)
+		}
 	}
 
-	// Initialize verifier
-	v := verifier.New(cfg)
+	// Prepare a result per email column of each row, in original order,
+	// marking the addresses an interruption left unverified as not checked.
+	// Rows repeating an earlier row's email are left out if asked.
+	var kept []io.Record
+	var ordered []io.RowResult
+	notChecked := 0
+	for i, record := range records {
+		if duplicateOf[i] != 0 && cfg.DropDuplicates {
+			continue
+		}
+		rowResult := io.RowResult{Results: make([]verifier.Result, len(columns)), DuplicateOf: duplicateOf[i]}
+		for k, column := range columns {
+			cell := io.Cell{Row: i, Column: column}
+			email := strings.TrimSpace(record.Values[column])
+			result, ok := results[cell]
+			if email != "" && !ok && p.stopped() {
+				result, ok = notCheckedResult(email), true
+			}
+			if email != "" && !ok {
+				log.Printf("Warning: No verification result found for email: %s. Setting to invalid.", email)
+				result = verifier.Result{
+					Email:              email,
+					VerificationStatus: "invalid",
+					ConfidenceScore:    0,
+				}
+			}
+			if result.VerificationStatus == "not_checked" {
+				notChecked++
+			}
+			rowResult.Results[k] = result
+		}
+		p.checkLead(record, rowResult.Results)
+		kept = append(kept, record)
+		ordered = append(ordered, rowResult)
+	}
+	p.checkLearnedPatterns(kept, ordered)
+
+	// Write results
+	err = io.WriteResults(cfg.OutputFile, cfg.OutputType, schema, columns, kept, ordered)
+	if err != nil {
+		log.Fatalf("Error writing results: %v", err)
+	}
+	return progress, notChecked
+}
+
+// pipeline holds what the verification workers share
+type pipeline struct {
+	verifier        *verifier.Verifier
+	cache           *cache.ResultCache
+	bypassCache     bool // verify every email, but still refresh the cache
+	deferred        *verifier.DeferredQueue
+	greylistRetries int // deferred re-verification passes
+	journal         *io.Journal
+	leadColumns     config.LeadColumns
+	stop            context.Context // done once no new batches should be started
+	numWorkers      int
+	patterns        *verifier.PatternLearner // nil unless the lead's name columns are configured
+}
 
-	// Process records concurrently
+// task is an email to verify and the row and column it came from
+type task struct {
+	row         io.Row
+	column      int
+	email       string
+	duplicateOf int // while streaming, the file row the first email column repeats
+}
+
+// cell returns where the task's email is in the input
+func (t task) cell() io.Cell {
+	return io.Cell{Row: t.row.Index, Column: t.column}
+}
+
+// outcome is the verification result of a task
+type outcome struct {
+	task
+	result verifier.Result
+	shared bool // reused from another occurrence of the address
+}
+
+// stopped reports whether the pipeline has been asked to stop
+func (p *pipeline) stopped() bool {
+	return p.stop.Err() != nil
+}
+
+// verify verifies tasks on a pool of workers and returns the results keyed by
+// cell. Once the pipeline is stopping, the workers finish the batches they
+// have and the remaining tasks are left without results.
+func (p *pipeline) verify(ctx context.Context, tasks []task, progress *progress) map[io.Cell]verifier.Result {
 	var wg sync.WaitGroup
-	recordsChan := make(chan map[string]string)
-	resultsChan := make(chan verifier.Result)
+	batchesChan := make(chan []task)
+	outcomes := make(chan outcome)
 
 	// Start workers
-	for i := 0; i < cfg.NumWorkers; i++ {
+	for i := 0; i < p.numWorkers; i++ {
 		wg.Add(1)
-		go worker(recordsChan, resultsChan, &wg, v, progress)
+		go p.worker(ctx, batchesChan, outcomes, &wg, progress)
 	}
 
-	// Send records to workers
+	// Send batches to workers until the pipeline stops
 	go func() {
-		for _, record := range records {
-			recordsChan <- record
-		}
-		close(recordsChan)
+		defer close(batchesChan)
+		p.dispatch(ctx, tasks, batchesChan)
 	}()
 
 	// Wait for all workers to finish
 	go func() {
 		wg.Wait()
-		close(resultsChan)
+		close(outcomes)
 	}()
 
 	// Collect results
-	resultsMap := make(map[string]verifier.Result)
-	for result := range resultsChan {
-		// Use lowercase email as key for case-insensitive matching
-		resultsMap[strings.ToLower(strings.TrimSpace(result.Email))] = result
-	}
-
-	// Prepare results in original order
-	results := make([]verifier.Result, 0, len(records))
-	for _, record := range records {
-		// Get email field (case-insensitive)
-		var email string
-		for key, value := range record {
-			if strings.EqualFold(key, "email") {
-				email = strings.TrimSpace(value)
-				break
-			}
-		}
+	results := make(map[io.Cell]verifier.Result)
+	for o := range outcomes {
+		results[o.cell()] = o.result
+	}
+	return results
+}
+
+// dispatch sends tasks to the workers one domain batch at a time, so
+// addresses at the same domain share an SMTP session. Once the pipeline is
+// stopping, the tasks that were not sent are returned.
+func (p *pipeline) dispatch(ctx context.Context, tasks []task, batchesChan chan<- []task) []task {
+	emails := make([]string, len(tasks))
+	for i, t := range tasks {
+		emails[i] = t.email
+	}
 
-		if email == "" {
+	batches := p.verifier.GroupByDomain(emails)
+	for b, batch := range batches {
+		batchTasks := make([]task, len(batch))
+		for k, i := range batch {
+			batchTasks[k] = tasks[i]
+		}
+		select {
+		case batchesChan <- batchTasks:
 			continue
+		case <-p.stop.Done():
+		case <-ctx.Done():
 		}
 
-		// Look up result by lowercase email for case-insensitive matching
-		if result, ok := resultsMap[strings.ToLower(email)]; ok {
-			results = append(results, result)
-		} else {
-			log.Printf("Warning: No verification result found for email: %s. Setting to invalid.", email)
-			results = append(results, verifier.Result{
-				Email:              email,
-				VerificationStatus: "invalid",
-				ConfidenceScore:    0,
-			})
+		var unsent []task
+		for _, rest := range batches[b:] {
+			for _, i := range rest {
+				unsent = append(unsent, tasks[i])
+			}
 		}
+		return unsent
 	}
-
-	// Write results
-	err = io.WriteResults(cfg.OutputFile, cfg.OutputType, records, results)
-	if err != nil {
-		log.Fatalf("Error writing results: %v", err)
-	}
-
-	// Print final statistics
-	elapsed := time.Since(progress.startTime)
-	fmt.Printf("\n\nVerification completed in %v\n", elapsed)
-	fmt.Printf("Total processed: %d\n", progress.processed)
-	fmt.Printf("Valid: %d (%.1f%%)\n", progress.valid, float64(progress.valid)*100/float64(progress.processed))
-	fmt.Printf("Risky: %d (%.1f%%)\n", progress.risky, float64(progress.risky)*100/float64(progress.processed))
-	fmt.Printf("Invalid: %d (%.1f%%)\n", progress.invalid, float64(progress.invalid)*100/float64(progress.processed))
-	fmt.Printf("Errors: %d (%.1f%%)\n", progress.errors, float64(progress.errors)*100/float64(progress.processed))
-	fmt.Printf("Results saved to %s\n", cfg.OutputFile)
+	return nil
 }
 
-func worker(recordsChan <-chan map[string]string, resultsChan chan<- verifier.Result, wg *sync.WaitGroup, v *verifier.Verifier, progress *progress) {
+func (p *pipeline) worker(ctx context.Context, batchesChan <-chan []task, outcomes chan<- outcome, wg *sync.WaitGroup, progress *progress) {
 	defer wg.Done()
 
-	for record := range recordsChan {
-		// Get email field (case-insensitive)
-		var email string
-		for key, value := range record {
-			if strings.EqualFold(key, "email") {
-				email = strings.TrimSpace(value)
-				break
+	for batch := range batchesChan {
+		// Serve cached results, verifying only the rest
+		pending := make([]task, 0, len(batch))
+		for _, t := range batch {
+			if result, ok := p.cache.Get(t.email); ok && !p.bypassCache {
+				p.checkpoint(t, result)
+				outcomes <- outcome{task: t, result: result}
+				progress.update(result.VerificationStatus)
+				continue
 			}
+			pending = append(pending, t)
 		}
 
-		if email == "" {
-			continue
+		emails := make([]string, len(pending))
+		for i, t := range pending {
+			emails[i] = t.email
 		}
+		lookups, errs := p.verifier.VerifyBatch(ctx, emails)
+		for i, t := range pending {
+			if verifier.ErrorCategoryOf(errs[i]) == verifier.CategoryCanceled {
+				// Abandoned on shutdown
+				outcomes <- outcome{task: t, result: notCheckedResult(t.email)}
+				continue
+			}
+			if errs[i] != nil {
+				log.Printf("Error verifying email %s after retries: %v. Marking as invalid.", t.email, errs[i])
+				result := verifier.FailedResult(t.email, "invalid", lookups[i], errs[i])
+				p.checkpoint(t, result)
+				outcomes <- outcome{task: t, result: result}
+				progress.update("error")
+				continue
+			}
 
-		result, err := v.VerifyWithRetry(email)
-		if err != nil {
-			log.Printf("Error verifying email %s after retries: %v. Marking as invalid.", email, err)
-			resultsChan <- verifier.Result{
-				Email:              email,
-				VerificationStatus: "invalid",
-				ConfidenceScore:    0,
+			if lookups[i].Greylisted {
+				p.deferred.Add(t.email)
 			}
-			progress.update("error")
-			continue
+
+			result := p.verifier.DetermineStatus(lookups[i], t.email)
+			p.cache.Put(result)
+			p.checkpoint(t, result)
+			outcomes <- outcome{task: t, result: result}
+			progress.update(result.VerificationStatus)
 		}
+	}
+}
 
-		verificationStatus, confidenceScore := v.DetermineStatus(result, email)
-		resultsChan <- verifier.Result{
-			Email:              email,
-			VerificationStatus: verificationStatus,
-			ConfidenceScore:    confidenceScore,
+// checkLeadColumns warns about lead columns that are configured but missing
+// from the input, whose checks will not run
+func (p *pipeline) checkLeadColumns(schema *io.Schema) {
+	for _, column := range []string{p.leadColumns.CompanyDomain, p.leadColumns.FirstName, p.leadColumns.LastName} {
+		if column != "" && !schema.Has(column) {
+			log.Printf("Warning: Lead column %q not found in input headers. Skipping its check.", column)
 		}
-		progress.update(verificationStatus)
+	}
+}
+
+// lead returns what a record says about the lead behind its addresses
+func (p *pipeline) lead(record io.Record) verifier.Lead {
+	var lead verifier.Lead
+	if p.leadColumns.CompanyDomain != "" {
+		lead.CompanyDomain = record.Get(p.leadColumns.CompanyDomain)
+	}
+	if p.leadColumns.FirstName != "" {
+		lead.FirstName = record.Get(p.leadColumns.FirstName)
+	}
+	if p.leadColumns.LastName != "" {
+		lead.LastName = record.Get(p.leadColumns.LastName)
+	}
+	return lead
+}
+
+// checkLead checks the results of a record's addresses against what the
+// record says about the lead, and learns their domains' name patterns
+func (p *pipeline) checkLead(record io.Record, results []verifier.Result) {
+	lead := p.lead(record)
+	for k, result := range results {
+		results[k] = p.verifier.CheckLead(result, lead)
+		if p.patterns != nil {
+			p.patterns.Observe(results[k], lead)
+		}
+	}
+}
+
+// checkLearnedPatterns rescores the addresses at catch-all domains against
+// the name patterns learned from the whole run
+func (p *pipeline) checkLearnedPatterns(records []io.Record, rowResults []io.RowResult) {
+	if p.patterns == nil {
+		return
+	}
+	for i, record := range records {
+		lead := p.lead(record)
+		for k, result := range rowResults[i].Results {
+			rowResults[i].Results[k] = p.verifier.CheckLearnedPattern(result, lead, p.patterns)
+		}
+	}
+}
+
+// writePatternReport writes the name patterns learned for each domain, if
+// any were learned from
+func (p *pipeline) writePatternReport(cfg *config.Config) {
+	if p.patterns == nil {
+		return
+	}
+	patterns := p.patterns.Patterns()
+	if len(patterns) == 0 {
+		return
+	}
+	reportPath := cfg.PatternLearning.ReportFile
+	if reportPath == "" {
+		reportPath = strings.TrimSuffix(cfg.OutputFile, filepath.Ext(cfg.OutputFile)) + "_patterns.csv"
+	}
+	if err := io.WritePatternReport(reportPath, patterns); err != nil {
+		log.Printf("Error writing pattern report: %v", err)
+		return
+	}
+	fmt.Printf("Patterns of %d domains saved to %s\n", len(patterns), reportPath)
+}
+
+// checkpoint journals the result of a task so a resumed run can skip it
+func (p *pipeline) checkpoint(t task, result verifier.Result) {
+	if err := p.journal.Append(t.cell(), result); err != nil {
+		log.Printf("Warning: Could not journal row %d: %v", t.row.Index, err)
 	}
 }
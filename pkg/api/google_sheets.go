@@ -4,8 +4,9 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
-	"strings"
 	"time"
+
+	"github.com/clau/email_verifier/pkg/verifier"
 )
 
 // GoogleSheetsRequest represents a request from Google Sheets
@@ -20,10 +21,8 @@ type GoogleSheetsResponse struct {
 
 // GoogleSheetsResult represents a single email verification result for Google Sheets
 type GoogleSheetsResult struct {
-	Email              string `json:"email"`
-	VerificationStatus string `json:"verification_status"`
-	ConfidenceScore    int    `json:"confidence_score"`
-	ProcessedAt        string `json:"processed_at"`
+	verifier.Result
+	ProcessedAt string `json:"processed_at"`
 }
 
 // handleGoogleSheetsRequest handles requests from Google Sheets
@@ -61,36 +60,15 @@ func (s *Server) handleGoogleSheetsRequest(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Process the emails
-	results := make([]GoogleSheetsResult, 0, len(req.Emails))
-	for _, email := range req.Emails {
-		email = strings.TrimSpace(email)
-		if email == "" {
-			continue
-		}
-
-		// Verify the email
-		result, err := s.verifier.VerifyWithRetry(email)
-		if err != nil {
-			log.Printf("Error verifying email %s: %v", email, err)
-			results = append(results, GoogleSheetsResult{
-				Email:              email,
-				VerificationStatus: "error",
-				ConfidenceScore:    0,
-				ProcessedAt:        time.Now().Format(time.RFC3339),
-			})
-			continue
-		}
-
-		// Determine the status
-		status, score := s.verifier.DetermineStatus(result, email)
+	// Verify the emails, sharing SMTP sessions per domain
+	verified := s.verifyEmails(r, cleanEmails(req.Emails))
 
-		// Add the result
+	// Process the results
+	results := make([]GoogleSheetsResult, 0, len(verified))
+	for _, result := range verified {
 		results = append(results, GoogleSheetsResult{
-			Email:              email,
-			VerificationStatus: status,
-			ConfidenceScore:    score,
-			ProcessedAt:        time.Now().Format(time.RFC3339),
+			Result:      result,
+			ProcessedAt: time.Now().Format(time.RFC3339),
 		})
 	}
 
@@ -1,22 +1,34 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/clau/email_verifier/pkg/cache"
 	"github.com/clau/email_verifier/pkg/config"
 	"github.com/clau/email_verifier/pkg/verifier"
 	"github.com/gorilla/mux"
 )
 
+// defaultShutdownTimeout is how long in-flight requests may take to finish
+// on shutdown when the config leaves it empty
+const defaultShutdownTimeout = 30 * time.Second
+
 // Server represents the API server
 type Server struct {
 	router   *mux.Router
 	verifier *verifier.Verifier
+	cache    *cache.ResultCache
 	config   *config.Config
 }
 
@@ -27,10 +39,14 @@ type VerifyRequest struct {
 
 // VerifyResponse represents the response from verifying an email
 type VerifyResponse struct {
-	Email              string `json:"email"`
-	VerificationStatus string `json:"verification_status"`
-	ConfidenceScore    int    `json:"confidence_score"`
-	ProcessedAt        string `json:"processed_at"`
+	verifier.Result
+	ProcessedAt string `json:"processed_at"`
+}
+
+// ErrorResponse represents the response when an email could not be verified
+type ErrorResponse struct {
+	Error         string                 `json:"error"`
+	ErrorCategory verifier.ErrorCategory `json:"error_category"`
 }
 
 // BatchVerifyRequest represents a request to verify multiple emails
@@ -43,14 +59,33 @@ type BatchVerifyResponse struct {
 	Results []VerifyResponse `json:"results"`
 }
 
+// FindRequest represents a request to find a person's email at a domain
+type FindRequest struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Domain    string `json:"domain"`
+}
+
+// FindResponse represents the response from finding an email
+type FindResponse struct {
+	verifier.FindResult
+	ProcessedAt string `json:"processed_at"`
+}
+
 // NewServer creates a new API server
 func NewServer(cfg *config.Config) *Server {
 	v := verifier.New(cfg)
 	r := mux.NewRouter()
 
+	resultCache, err := cache.New(cfg.Cache)
+	if err != nil {
+		log.Printf("Warning: Could not open cache: %v. Continuing without cache.", err)
+	}
+
 	server := &Server{
 		router:   r,
 		verifier: v,
+		cache:    resultCache,
 		config:   cfg,
 	}
 
@@ -58,6 +93,7 @@ func NewServer(cfg *config.Config) *Server {
 	r.HandleFunc("/health", server.healthHandler).Methods("GET")
 	r.HandleFunc("/verify", server.verifyHandler).Methods("POST")
 	r.HandleFunc("/batch-verify", server.batchVerifyHandler).Methods("POST")
+	r.HandleFunc("/find", server.findHandler).Methods("POST")
 	r.HandleFunc("/google-sheets", server.handleGoogleSheetsRequest).Methods("POST", "OPTIONS")
 
 	// Add middleware for logging and CORS
@@ -67,11 +103,46 @@ func NewServer(cfg *config.Config) *Server {
 	return server
 }
 
-// Start starts the API server
+// Start runs the API server until it receives SIGINT or SIGTERM, then stops
+// accepting connections and waits up to the shutdown timeout for in-flight
+// requests before closing them
 func (s *Server) Start(port int) error {
 	addr := fmt.Sprintf(":%d", port)
-	log.Printf("Starting API server on %s", addr)
-	return http.ListenAndServe(addr, s.router)
+	httpServer := &http.Server{Addr: addr, Handler: s.router}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Starting API server on %s", addr)
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	timeout := s.config.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	log.Printf("Shutting down API server, waiting up to %v for in-flight requests", timeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := httpServer.Shutdown(shutdownCtx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Printf("Shutdown timeout passed, closing remaining connections")
+		err = httpServer.Close()
+	}
+
+	if cacheErr := s.cache.Close(); cacheErr != nil {
+		log.Printf("Error closing cache: %v", cacheErr)
+	}
+	return err
 }
 
 // healthHandler handles health check requests
@@ -97,20 +168,33 @@ func (s *Server) verifyHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	email := strings.TrimSpace(req.Email)
-	result, err := s.verifier.VerifyWithRetry(email)
+	if result, ok := s.cachedResult(r, email); ok {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(VerifyResponse{
+			Result:      result,
+			ProcessedAt: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+
+	lookup, err := s.verifier.VerifyContext(r.Context(), email)
 	if err != nil {
 		log.Printf("Error verifying email %s: %v", email, err)
-		http.Error(w, "Error verifying email", http.StatusInternalServerError)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:         "Error verifying email",
+			ErrorCategory: verifier.ErrorCategoryOf(err),
+		})
 		return
 	}
 
-	status, score := s.verifier.DetermineStatus(result, email)
+	result := s.verifier.DetermineStatus(lookup, email)
+	s.cache.Put(result)
 
 	response := VerifyResponse{
-		Email:              email,
-		VerificationStatus: status,
-		ConfidenceScore:    score,
-		ProcessedAt:        time.Now().Format(time.RFC3339),
+		Result:      result,
+		ProcessedAt: time.Now().Format(time.RFC3339),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -135,42 +219,109 @@ func (s *Server) batchVerifyHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	results := make([]VerifyResponse, 0, len(req.Emails))
+	verified := s.verifyEmails(r, cleanEmails(req.Emails))
+	results := make([]VerifyResponse, 0, len(verified))
+	for _, result := range verified {
+		results = append(results, VerifyResponse{
+			Result:      result,
+			ProcessedAt: time.Now().Format(time.RFC3339),
+		})
+	}
+
+	response := BatchVerifyResponse{
+		Results: results,
+	}
 
-	for _, email := range req.Emails {
-		email = strings.TrimSpace(email)
-		if email == "" {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// findHandler handles requests to find a person's email from their name and
+// company domain
+func (s *Server) findHandler(w http.ResponseWriter, r *http.Request) {
+	var req FindRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	lead := verifier.Lead{CompanyDomain: req.Domain, FirstName: req.FirstName, LastName: req.LastName}
+	find, err := s.verifier.Find(r.Context(), lead)
+	if errors.Is(err, verifier.ErrIncompleteLead) {
+		http.Error(w, "A first or last name and a domain are required", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding email for %s %s at %s: %v", req.FirstName, req.LastName, req.Domain, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(ErrorResponse{
+			Error:         "Error finding email",
+			ErrorCategory: verifier.ErrorCategoryOf(err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(FindResponse{
+		FindResult:  *find,
+		ProcessedAt: time.Now().Format(time.RFC3339),
+	})
+}
+
+// verifyEmails verifies the emails of a batch request in order, serving
+// cached results unless the request bypasses the cache. Emails that could
+// not be verified get the "error" status.
+func (s *Server) verifyEmails(r *http.Request, emails []string) []verifier.Result {
+	results := make([]verifier.Result, len(emails))
+
+	// Serve cached results, verifying only the rest
+	var pending []string
+	var pendingIdx []int
+	for i, email := range emails {
+		if result, ok := s.cachedResult(r, email); ok {
+			results[i] = result
 			continue
 		}
+		pending = append(pending, email)
+		pendingIdx = append(pendingIdx, i)
+	}
 
-		result, err := s.verifier.VerifyWithRetry(email)
-		if err != nil {
-			log.Printf("Error verifying email %s: %v", email, err)
-			results = append(results, VerifyResponse{
-				Email:              email,
-				VerificationStatus: "error",
-				ConfidenceScore:    0,
-				ProcessedAt:        time.Now().Format(time.RFC3339),
-			})
+	lookups, errs := s.verifier.VerifyBatch(r.Context(), pending)
+	for k, email := range pending {
+		if errs[k] != nil {
+			log.Printf("Error verifying email %s: %v", email, errs[k])
+			results[pendingIdx[k]] = verifier.FailedResult(email, "error", lookups[k], errs[k])
 			continue
 		}
 
-		status, score := s.verifier.DetermineStatus(result, email)
-
-		results = append(results, VerifyResponse{
-			Email:              email,
-			VerificationStatus: status,
-			ConfidenceScore:    score,
-			ProcessedAt:        time.Now().Format(time.RFC3339),
-		})
+		result := s.verifier.DetermineStatus(lookups[k], email)
+		s.cache.Put(result)
+		results[pendingIdx[k]] = result
 	}
 
-	response := BatchVerifyResponse{
-		Results: results,
+	return results
+}
+
+// cachedResult returns the cached result for an email unless the request
+// asks to bypass the cache with ?no_cache=true
+func (s *Server) cachedResult(r *http.Request, email string) (verifier.Result, bool) {
+	if bypass, _ := strconv.ParseBool(r.URL.Query().Get("no_cache")); bypass {
+		return verifier.Result{}, false
 	}
+	return s.cache.Get(email)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+// cleanEmails trims the emails in a batch request and drops empty ones
+func cleanEmails(emails []string) []string {
+	cleaned := make([]string, 0, len(emails))
+	for _, email := range emails {
+		email = strings.TrimSpace(email)
+		if email != "" {
+			cleaned = append(cleaned, email)
+		}
+	}
+	return cleaned
 }
 
 // loggingMiddleware logs all requests
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/clau/email_verifier/pkg/config"
+	"github.com/clau/email_verifier/pkg/verifier"
+)
+
+// Cache is a key-value store for verification results with expiry
+type Cache interface {
+	// Get returns the result stored under key, if present and not expired
+	Get(key string) (verifier.Result, bool)
+	// Set stores the result under key until ttl has passed
+	Set(key string, result verifier.Result, ttl time.Duration)
+	// Close flushes and releases the store
+	Close() error
+}
+
+// Default cache settings used when the config leaves them empty
+const (
+	defaultSize = 10000
+	defaultPath = ".verification_cache.jsonl"
+)
+
+// ResultCache caches verification results in front of the verifier, keeping
+// each one for a TTL that depends on its status. A nil *ResultCache caches
+// nothing.
+type ResultCache struct {
+	store Cache
+	ttls  config.CacheTTLs
+}
+
+// New creates the result cache described by the config, or returns nil when
+// caching is disabled
+func New(cfg config.CacheConfig) (*ResultCache, error) {
+	size := cfg.Size
+	if size <= 0 {
+		size = defaultSize
+	}
+
+	var store Cache
+	switch strings.ToLower(cfg.Type) {
+	case "", "none":
+		return nil, nil
+	case "memory":
+		store = NewMemory(size)
+	case "disk":
+		path := cfg.Path
+		if path == "" {
+			path = defaultPath
+		}
+		disk, err := NewDisk(path, size)
+		if err != nil {
+			return nil, err
+		}
+		store = disk
+	default:
+		return nil, fmt.Errorf("unsupported cache type: %s", cfg.Type)
+	}
+	return &ResultCache{store: store, ttls: cfg.TTL}, nil
+}
+
+// Key returns the cache key for an email: its canonical form, so aliases of
+// a mailbox share one entry
+func Key(email string) string {
+	return verifier.NormalizeEmail(email)
+}
+
+// Get returns the cached result for an email
+func (c *ResultCache) Get(email string) (verifier.Result, bool) {
+	if c == nil {
+		return verifier.Result{}, false
+	}
+	result, ok := c.store.Get(Key(email))
+	if ok {
+		// Report the address as it was asked for, not as it was first cached
+		result = result.As(email)
+	}
+	return result, ok
+}
+
+// Put caches a verification result for the TTL of its status. Failed and
+// greylisted verifications are not cached.
+func (c *ResultCache) Put(result verifier.Result) {
+	if c == nil || result.ErrorCategory != "" || result.Checks == nil || result.Checks.Greylisted {
+		return
+	}
+	if ttl := c.ttl(result); ttl > 0 {
+		c.store.Set(Key(result.Email), result, ttl)
+	}
+}
+
+// Close flushes and releases the underlying store
+func (c *ResultCache) Close() error {
+	if c == nil {
+		return nil
+	}
+	return c.store.Close()
+}
+
+// ttl picks how long to keep a result: by status, or the shorter "unknown"
+// TTL when the mailbox could not be confirmed
+func (c *ResultCache) ttl(result verifier.Result) time.Duration {
+	var ttl time.Duration
+	switch result.VerificationStatus {
+	case "valid":
+		ttl = c.ttls.Valid
+	case "risky":
+		ttl = c.ttls.Risky
+	case "invalid":
+		ttl = c.ttls.Invalid
+	}
+	if result.Checks.Reachable == "unknown" && c.ttls.Unknown < ttl {
+		ttl = c.ttls.Unknown
+	}
+	return ttl
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/clau/email_verifier/pkg/verifier"
+)
+
+// Disk is a cache persisted to a file of JSON lines, one entry per line.
+// Up to size entries are held in memory, the most recently used winning, and
+// entries are appended to the file as they are set. The file is rewritten
+// without expired, evicted or superseded entries once it holds twice as many
+// lines as size, and on Close. Lines that cannot be read, such as one cut
+// short by a crash, are skipped and dropped. Only one process may use a cache file at a
+// time: processes sharing one, such as the CLI and the API server, would
+// overwrite each other's entries when rewriting it.
+type Disk struct {
+	mu     sync.Mutex
+	path   string
+	size   int
+	file   *os.File
+	lines  int // entries in the file, including stale ones
+	memory *Memory
+}
+
+// NewDisk opens (or creates) the cache file at path, holding up to size
+// entries
+func NewDisk(path string, size int) (*Disk, error) {
+	memory := NewMemory(size)
+	lines, skipped, err := load(path, memory)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	d := &Disk{path: path, size: size, file: file, lines: lines, memory: memory}
+	if skipped > 0 {
+		// Drop the unreadable lines, so new entries are not appended to one
+		// cut short
+		d.compact()
+	}
+	return d, nil
+}
+
+// load reads the unexpired entries of a cache file into memory, returning the
+// number of lines in the file and how many of them could not be read
+func load(path string, memory *Memory) (lines, skipped int, err error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	now := time.Now()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines++
+		var e entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			skipped++
+			continue
+		}
+		if now.Before(e.Expires) {
+			memory.set(&e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, fmt.Errorf("error reading cache file %s: %v", path, err)
+	}
+	return lines, skipped, nil
+}
+
+// Get returns the result stored under key, if present and not expired
+func (d *Disk) Get(key string) (verifier.Result, bool) {
+	return d.memory.Get(key)
+}
+
+// Set stores the result under key until ttl has passed
+func (d *Disk) Set(key string, result verifier.Result, ttl time.Duration) {
+	e := &entry{Key: key, Result: result, Expires: time.Now().Add(ttl)}
+	d.memory.set(e)
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.file.Write(append(line, '\n'))
+	if d.lines++; d.lines > 2*d.size {
+		d.compact()
+	}
+}
+
+// compact rewrites the cache file to the entries held in memory and goes on
+// appending to the new file. If the rewrite fails, the old file is still in
+// place and entries go on being appended to it.
+func (d *Disk) compact() {
+	file, err := d.rewrite()
+	if err != nil {
+		return
+	}
+	d.file.Close()
+	d.file = file
+}
+
+// Close compacts the cache file to its unexpired entries and closes it
+func (d *Disk) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.file.Close(); err != nil {
+		return err
+	}
+	file, err := d.rewrite()
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// rewrite replaces the cache file with the unexpired entries held in memory,
+// returning the new file open for appending. The file is opened before it
+// replaces the old one, so there is no moment when appends could go to a
+// file that is no longer in place.
+func (d *Disk) rewrite() (*os.File, error) {
+	entries := d.memory.live()
+	tmpPath := d.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, e := range entries {
+		if err := encoder.Encode(e); err != nil {
+			tmp.Close()
+			return nil, err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, d.path); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	d.lines = len(entries)
+	return tmp, nil
+}
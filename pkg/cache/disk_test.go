@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/clau/email_verifier/pkg/verifier"
+)
+
+func TestDiskBoundedBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.jsonl")
+	disk, err := NewDisk(path, 2)
+	if err != nil {
+		t.Fatalf("NewDisk() error = %v", err)
+	}
+	for i := 1; i <= 7; i++ {
+		key := fmt.Sprintf("user%d@example.com", i)
+		disk.Set(key, verifier.Result{Email: key, VerificationStatus: "valid"}, time.Hour)
+
+		// The file is compacted once it holds twice as many entries as fit in memory
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("reading cache file: %v", err)
+		}
+		if lines := bytes.Count(data, []byte("\n")); lines > 4 {
+			t.Fatalf("after %d entries the cache file has %d lines, want at most 4", i, lines)
+		}
+	}
+	if err := disk.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	reopened, err := NewDisk(path, 2)
+	if err != nil {
+		t.Fatalf("NewDisk() error = %v", err)
+	}
+	defer reopened.Close()
+	for i := 1; i <= 7; i++ {
+		key := fmt.Sprintf("user%d@example.com", i)
+		_, ok := reopened.Get(key)
+		if want := i >= 6; ok != want {
+			t.Errorf("Get(%s) found = %t, want %t", key, ok, want)
+		}
+	}
+}
+
+func TestDiskSkipsUnreadableLines(t *testing.T) {
+	// A crash while setting an entry leaves its line cut short
+	path := filepath.Join(t.TempDir(), "cache.jsonl")
+	disk, err := NewDisk(path, 10)
+	if err != nil {
+		t.Fatalf("NewDisk() error = %v", err)
+	}
+	disk.Set("first@example.com", verifier.Result{Email: "first@example.com"}, time.Hour)
+	disk.file.WriteString(`{"key":"cut@example.com","result":{"em`)
+	disk.file.Close()
+
+	reopened, err := NewDisk(path, 10)
+	if err != nil {
+		t.Fatalf("NewDisk() error = %v", err)
+	}
+	reopened.Set("second@example.com", verifier.Result{Email: "second@example.com"}, time.Hour)
+	if err := reopened.file.Close(); err != nil {
+		t.Fatalf("closing cache file: %v", err)
+	}
+
+	// Entries set after the cut are not lost to it
+	again, err := NewDisk(path, 10)
+	if err != nil {
+		t.Fatalf("NewDisk() error = %v", err)
+	}
+	defer again.Close()
+	for _, key := range []string{"first@example.com", "second@example.com"} {
+		if _, ok := again.Get(key); !ok {
+			t.Errorf("Get(%s) found nothing", key)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/clau/email_verifier/pkg/verifier"
+)
+
+// entry is a cached result with its expiry time
+type entry struct {
+	Key     string          `json:"key"`
+	Result  verifier.Result `json:"result"`
+	Expires time.Time       `json:"expires"`
+}
+
+// Memory is an in-memory cache that evicts the least recently used entry
+// once it holds size entries
+type Memory struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // most recently used at the front
+	entries map[string]*list.Element
+}
+
+// NewMemory creates an in-memory LRU cache holding up to size entries
+func NewMemory(size int) *Memory {
+	return &Memory{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the result stored under key, if present and not expired
+func (m *Memory) Get(key string) (verifier.Result, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, ok := m.entries[key]
+	if !ok {
+		return verifier.Result{}, false
+	}
+	e := elem.Value.(*entry)
+	if time.Now().After(e.Expires) {
+		m.order.Remove(elem)
+		delete(m.entries, key)
+		return verifier.Result{}, false
+	}
+	m.order.MoveToFront(elem)
+	return e.Result, true
+}
+
+// Set stores the result under key until ttl has passed
+func (m *Memory) Set(key string, result verifier.Result, ttl time.Duration) {
+	m.set(&entry{Key: key, Result: result, Expires: time.Now().Add(ttl)})
+}
+
+func (m *Memory) set(e *entry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.entries[e.Key]; ok {
+		elem.Value = e
+		m.order.MoveToFront(elem)
+		return
+	}
+	m.entries[e.Key] = m.order.PushFront(e)
+
+	for m.order.Len() > m.size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*entry).Key)
+	}
+}
+
+// live returns the unexpired entries, least recently used first
+func (m *Memory) live() []*entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	entries := make([]*entry, 0, m.order.Len())
+	for elem := m.order.Back(); elem != nil; elem = elem.Prev() {
+		if e := elem.Value.(*entry); now.Before(e.Expires) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Close does nothing; the memory cache holds no resources
+func (m *Memory) Close() error {
+	return nil
+}
@@ -11,27 +11,109 @@ import (
 
 // Config holds the configurable parameters
 type Config struct {
-	InputFile         string         `yaml:"input_file"`
-	InputType         string         `yaml:"input_type"`
-	OutputFile        string         `yaml:"output_file"`
-	OutputType        string         `yaml:"output_type"`
-	ValidThreshold    int            `yaml:"valid_threshold"`
-	RiskyThreshold    int            `yaml:"risky_threshold"`
-	DefaultRiskyScore int            `yaml:"default_risky_score"`
-	MaxRetries        int            `yaml:"max_retries"`
-	InitialBackoff    time.Duration  `yaml:"initial_backoff"`
-	NumWorkers        int            `yaml:"num_workers"`
-	ScoringWeights    ScoringWeights `yaml:"scoring_weights"`
+	InputFile               string         `yaml:"input_file"`
+	InputType               string         `yaml:"input_type"`
+	OutputFile              string         `yaml:"output_file"`
+	OutputType              string         `yaml:"output_type"`
+	EmailColumns            []string       `yaml:"email_columns"`
+	LeadColumns             LeadColumns    `yaml:"lead_columns"`
+	DropDuplicates          bool           `yaml:"drop_duplicates"`
+	PatternLearning         PatternConfig  `yaml:"pattern_learning"`
+	JournalFile             string         `yaml:"journal_file"`
+	ValidThreshold          int            `yaml:"valid_threshold"`
+	RiskyThreshold          int            `yaml:"risky_threshold"`
+	DefaultRiskyScore       int            `yaml:"default_risky_score"`
+	MaxRetries              int            `yaml:"max_retries"`
+	InitialBackoff          time.Duration  `yaml:"initial_backoff"`
+	AttemptTimeout          time.Duration  `yaml:"attempt_timeout"`
+	VerifyTimeout           time.Duration  `yaml:"verify_timeout"`
+	NumWorkers              int            `yaml:"num_workers"`
+	ShutdownTimeout         time.Duration  `yaml:"shutdown_timeout"`
+	SMTPPort                int            `yaml:"smtp_port"`
+	SMTPTimeout             time.Duration  `yaml:"smtp_timeout"`
+	HelloName               string         `yaml:"hello_name"`
+	FromEmail               string         `yaml:"from_email"`
+	MaxRecipientsPerSession int            `yaml:"max_recipients_per_session"`
+	RateLimits              RateLimits     `yaml:"rate_limits"`
+	GreylistDelay           time.Duration  `yaml:"greylist_delay"`
+	GreylistRetries         int            `yaml:"greylist_retries"`
+	Cache                   CacheConfig    `yaml:"cache"`
+	DomainCacheTTL          time.Duration  `yaml:"domain_cache_ttl"`
+	DNS                     DNSConfig      `yaml:"dns"`
+	ScoringWeights          ScoringWeights `yaml:"scoring_weights"`
+}
+
+// RateLimits caps SMTP requests per second, overall and per recipient domain
+// and MX host
+type RateLimits struct {
+	Global    float64 `yaml:"global"`
+	PerDomain float64 `yaml:"per_domain"`
+	PerMX     float64 `yaml:"per_mx"`
+	Burst     int     `yaml:"burst"`
+}
+
+// CacheConfig selects where verification results are cached and for how long
+type CacheConfig struct {
+	Type string    `yaml:"type"` // none, memory or disk
+	Path string    `yaml:"path"` // cache file for the disk cache
+	Size int       `yaml:"size"` // maximum entries held in memory, for both cache types
+	TTL  CacheTTLs `yaml:"ttl"`
+}
+
+// CacheTTLs sets how long results are cached by status. Unknown applies when
+// the mailbox could not be confirmed, if shorter than the status TTL.
+type CacheTTLs struct {
+	Valid   time.Duration `yaml:"valid"`
+	Risky   time.Duration `yaml:"risky"`
+	Invalid time.Duration `yaml:"invalid"`
+	Unknown time.Duration `yaml:"unknown"`
+}
+
+// DNSConfig selects the resolver used for DNS lookups: the system resolver,
+// specific nameservers over UDP or TCP, or a DNS-over-HTTPS server
+type DNSConfig struct {
+	Type        string        `yaml:"type"`        // system, udp, tcp or doh
+	Nameservers []string      `yaml:"nameservers"` // host or host:port, for udp and tcp
+	DoHURL      string        `yaml:"doh_url"`     // DNS-over-HTTPS endpoint, for doh
+	Timeout     time.Duration `yaml:"timeout"`
 }
 
 // ScoringWeights to manage individual weights in config
 type ScoringWeights struct {
 	HasMxRecords     int `yaml:"has_mx_records"`
+	ImplicitMX       int `yaml:"implicit_mx"`
 	ReachableYes     int `yaml:"reachable_yes"`
 	ReachableUnknown int `yaml:"reachable_unknown"`
 	RoleAccount      int `yaml:"role_account"`
 	FreeProvider     int `yaml:"free_provider"`
 	Suggestion       int `yaml:"suggestion"`
+	CatchAll         int `yaml:"catch_all"`
+	NoSPF            int `yaml:"no_spf"`
+	NoDMARC          int `yaml:"no_dmarc"`
+
+	// Lead checks, applied when the record has the columns in LeadColumns
+	PersonalEmailForBusinessLead int `yaml:"personal_email_for_business_lead"`
+	DomainMismatch               int `yaml:"domain_mismatch"`
+	NameMatch                    int `yaml:"name_match"`
+	NameMismatch                 int `yaml:"name_mismatch"`
+	LearnedPatternMatch          int `yaml:"learned_pattern_match"`
+}
+
+// LeadColumns names the input columns describing the lead behind each
+// address. A check runs only when its columns are named and present.
+type LeadColumns struct {
+	CompanyDomain string `yaml:"company_domain"` // the company's domain or website URL
+	FirstName     string `yaml:"first_name"`
+	LastName      string `yaml:"last_name"`
+}
+
+// PatternConfig sets how each domain's naming convention is learned from
+// the verified addresses of a run. A domain's dominant pattern is learned
+// once it has MinSamples addresses and at least MinShare of them follow it.
+type PatternConfig struct {
+	MinSamples int     `yaml:"min_samples"`
+	MinShare   float64 `yaml:"min_share"`
+	ReportFile string  `yaml:"report_file"` // per-domain pattern report, a CSV file
 }
 
 // LoadConfig loads and validates configuration from a YAML file
@@ -58,5 +140,21 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("invalid output_type in config.yaml: %s. Must be 'csv' or 'xlsx'", config.OutputType)
 	}
 
+	// Validate the resolver settings
+	config.DNS.Type = strings.ToLower(config.DNS.Type)
+	switch config.DNS.Type {
+	case "", "system":
+	case "udp", "tcp":
+		if len(config.DNS.Nameservers) == 0 {
+			return nil, fmt.Errorf("dns.nameservers in config.yaml must list at least one nameserver for dns type %s", config.DNS.Type)
+		}
+	case "doh":
+		if config.DNS.DoHURL == "" {
+			return nil, fmt.Errorf("dns.doh_url in config.yaml is required for dns type doh")
+		}
+	default:
+		return nil, fmt.Errorf("invalid dns.type in config.yaml: %s. Must be 'system', 'udp', 'tcp' or 'doh'", config.DNS.Type)
+	}
+
 	return config, nil
 }
@@ -0,0 +1,94 @@
+package io
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/clau/email_verifier/pkg/verifier"
+	"github.com/tealeg/xlsx"
+)
+
+// findHeaders are the result columns of find mode appended after the
+// original fields
+var findHeaders = []string{
+	"find status",
+	"found email",
+	"found pattern",
+	"found confidence score",
+}
+
+// WriteFindResults writes the records with the outcome of looking for their
+// addresses to either CSV or XLSX file. results holds the outcome for each
+// record, nil for records that were not looked up.
+func WriteFindResults(filePath, fileType string, schema *Schema, records []Record, results []*verifier.FindResult) error {
+	headers := appendHeaders(schema.Headers(), findHeaders)
+	rows := make([][]string, len(records))
+	for i, record := range records {
+		rows[i] = append(append([]string{}, record.Values...), getFindDetails(results[i])...)
+	}
+
+	switch strings.ToLower(fileType) {
+	case "csv":
+		return writeRowsToCSV(filePath, headers, rows)
+	case "xlsx":
+		return writeRowsToXLSX(filePath, headers, rows)
+	default:
+		return fmt.Errorf("unsupported file type: %s", fileType)
+	}
+}
+
+// getFindDetails returns the values of the find columns, all empty for a
+// record that was not looked up
+func getFindDetails(result *verifier.FindResult) []string {
+	details := make([]string, len(findHeaders))
+	if result == nil {
+		return details
+	}
+	details[0] = result.Status
+	details[1] = result.Email
+	details[2] = result.Pattern
+	if result.Email != "" {
+		details[3] = strconv.Itoa(result.ConfidenceScore)
+	}
+	return details
+}
+
+// writeRowsToCSV writes a header row and data rows to a CSV file
+func writeRowsToCSV(filePath string, headers []string, rows [][]string) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write(headers); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// writeRowsToXLSX writes a header row and data rows to an Excel file
+func writeRowsToXLSX(filePath string, headers []string, rows [][]string) error {
+	file := xlsx.NewFile()
+	sheet, err := file.AddSheet("found leads")
+	if err != nil {
+		return err
+	}
+
+	for _, values := range append([][]string{headers}, rows...) {
+		row := sheet.AddRow()
+		for _, value := range values {
+			cell := row.AddCell()
+			cell.SetString(value)
+		}
+	}
+
+	return file.Save(filePath)
+}
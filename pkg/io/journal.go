@@ -0,0 +1,209 @@
+package io
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/clau/email_verifier/pkg/verifier"
+)
+
+// Journal is an append-only checkpoint of verified rows, one JSON line per
+// result, so an interrupted run can resume without verifying them again
+type Journal struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// Cell identifies the address in one email column of a row
+type Cell struct {
+	Row    int `json:"row"`
+	Column int `json:"column"`
+}
+
+// journalEntry is one line of the journal. A cell may appear several times;
+// the last entry wins.
+type journalEntry struct {
+	Cell
+	Result verifier.Result `json:"result"`
+}
+
+// OpenJournal opens the journal at path for appending. Unless resuming, any
+// previous journal is discarded.
+func OpenJournal(path string, resume bool) (*Journal, error) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if !resume {
+		flags |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	// Terminate a line cut short by a crash so new entries start cleanly
+	if resume && !endsWithNewline(path) {
+		file.Write([]byte{'\n'})
+	}
+	return &Journal{path: path, file: file}, nil
+}
+
+// LoadJournal reads the results recorded in the journal at path, keyed by
+// cell. A missing journal holds no results. Lines that cannot be parsed,
+// such as one cut short by a crash, are skipped and their rows verified again.
+func LoadJournal(path string) (map[Cell]verifier.Result, error) {
+	results := make(map[Cell]verifier.Result)
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		results[e.Cell] = e.Result
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading journal %s: %v", path, err)
+	}
+	return results, nil
+}
+
+// endsWithNewline reports whether the file at path is empty or ends with a
+// newline
+func endsWithNewline(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return true
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return true
+	}
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return true
+	}
+	return last[0] == '\n'
+}
+
+// JournalIndex locates the results recorded in a journal by cell and reads
+// each back from the file only when it is asked for, so it holds a position
+// per cell in memory rather than a whole result
+type JournalIndex struct {
+	file  *os.File
+	lines map[Cell]journalLine
+}
+
+// journalLine is where a cell's last entry is in the journal file
+type journalLine struct {
+	offset int64
+	length int
+}
+
+// IndexJournal indexes the results recorded in the journal at path. A
+// missing journal holds no results. Lines that cannot be parsed are skipped,
+// as by LoadJournal.
+func IndexJournal(path string) (*JournalIndex, error) {
+	index := &JournalIndex{lines: make(map[Cell]journalLine)}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var offset int64
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var e struct{ Cell }
+		if err := json.Unmarshal(line, &e); err == nil {
+			index.lines[e.Cell] = journalLine{offset: offset, length: len(line)}
+		}
+		offset += int64(len(line)) + 1
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error reading journal %s: %v", path, err)
+	}
+	index.file = file
+	return index, nil
+}
+
+// Len returns the number of cells with a result
+func (x *JournalIndex) Len() int {
+	if x == nil {
+		return 0
+	}
+	return len(x.lines)
+}
+
+// Result reads back the result recorded for a cell. A nil index holds no
+// results.
+func (x *JournalIndex) Result(cell Cell) (verifier.Result, bool) {
+	if x == nil {
+		return verifier.Result{}, false
+	}
+	line, ok := x.lines[cell]
+	if !ok {
+		return verifier.Result{}, false
+	}
+	data := make([]byte, line.length)
+	if _, err := x.file.ReadAt(data, line.offset); err != nil {
+		return verifier.Result{}, false
+	}
+	var e journalEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return verifier.Result{}, false
+	}
+	return e.Result, true
+}
+
+// Close closes the journal file the index reads from
+func (x *JournalIndex) Close() error {
+	if x == nil || x.file == nil {
+		return nil
+	}
+	return x.file.Close()
+}
+
+// Append records the result of a cell
+func (j *Journal) Append(cell Cell, result verifier.Result) error {
+	line, err := json.Marshal(journalEntry{Cell: cell, Result: result})
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the journal, keeping it on disk
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
+
+// Remove closes and deletes the journal once the run it checkpoints is done
+func (j *Journal) Remove() error {
+	j.file.Close()
+	return os.Remove(j.path)
+}
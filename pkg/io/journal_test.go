@@ -0,0 +1,87 @@
+package io
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/clau/email_verifier/pkg/verifier"
+)
+
+func TestIndexJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.csv.journal")
+	journal, err := OpenJournal(path, false)
+	if err != nil {
+		t.Fatalf("OpenJournal() error = %v", err)
+	}
+	entries := []struct {
+		cell   Cell
+		result verifier.Result
+	}{
+		{Cell{Row: 1}, verifier.Result{Email: "a@example.com", VerificationStatus: "risky"}},
+		{Cell{Row: 2, Column: 1}, verifier.Result{Email: "b@example.com", VerificationStatus: "valid", Reasons: []verifier.Reason{{Code: verifier.ReasonMailboxExists, Weight: 50}}}},
+		{Cell{Row: 1}, verifier.Result{Email: "a@example.com", VerificationStatus: "valid"}},
+	}
+	for _, e := range entries {
+		if err := journal.Append(e.cell, e.result); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	journal.file.WriteString(`{"row":3,"column":0,"result":{"em`)
+	journal.Close()
+
+	index, err := IndexJournal(path)
+	if err != nil {
+		t.Fatalf("IndexJournal() error = %v", err)
+	}
+	defer index.Close()
+	loaded, err := LoadJournal(path)
+	if err != nil {
+		t.Fatalf("LoadJournal() error = %v", err)
+	}
+
+	// The index reads back what loading the whole journal gives
+	if index.Len() != len(loaded) || index.Len() != 2 {
+		t.Errorf("Len() = %d, want %d", index.Len(), len(loaded))
+	}
+	for _, cell := range []Cell{{Row: 1}, {Row: 2, Column: 1}, {Row: 3}} {
+		result, ok := index.Result(cell)
+		want, wantOK := loaded[cell]
+		if ok != wantOK || !reflect.DeepEqual(result, want) {
+			t.Errorf("Result(%+v) = %+v, %t; want %+v, %t", cell, result, ok, want, wantOK)
+		}
+	}
+	if result, _ := index.Result(Cell{Row: 1}); result.VerificationStatus != "valid" {
+		t.Errorf("Result(row 1) status = %q, want the last entry's valid", result.VerificationStatus)
+	}
+}
+
+func TestIndexJournalMissingOrCut(t *testing.T) {
+	index, err := IndexJournal(filepath.Join(t.TempDir(), "missing.journal"))
+	if err != nil {
+		t.Fatalf("IndexJournal() error = %v", err)
+	}
+	if _, ok := index.Result(Cell{Row: 1}); ok || index.Len() != 0 {
+		t.Errorf("missing journal has results")
+	}
+	if err := index.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	// Resuming terminates a line cut short so new entries start cleanly
+	path := filepath.Join(t.TempDir(), "cut.journal")
+	if err := os.WriteFile(path, []byte(`{"row":1`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	journal, err := OpenJournal(path, true)
+	if err != nil {
+		t.Fatalf("OpenJournal() error = %v", err)
+	}
+	journal.Append(Cell{Row: 2}, verifier.Result{Email: "b@example.com"})
+	journal.Close()
+	if index, err = IndexJournal(path); err != nil || index.Len() != 1 {
+		t.Errorf("IndexJournal() = %d results, %v; want 1", index.Len(), err)
+	}
+	index.Close()
+}
@@ -0,0 +1,40 @@
+package io
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/clau/email_verifier/pkg/verifier"
+)
+
+// patternReportHeaders are the columns of the per-domain pattern report
+var patternReportHeaders = []string{
+	"domain",
+	"pattern",
+	"matches",
+	"samples",
+	"share",
+	"learned",
+}
+
+// WritePatternReport writes the most common name pattern of each domain's
+// addresses to a CSV file, and whether it was learned as the domain's
+// convention. Domains whose addresses follow no pattern have "none".
+func WritePatternReport(filePath string, patterns []verifier.DomainPattern) error {
+	rows := make([][]string, len(patterns))
+	for i, learned := range patterns {
+		pattern := learned.Pattern
+		if pattern == "" {
+			pattern = "none"
+		}
+		rows[i] = []string{
+			learned.Domain,
+			pattern,
+			strconv.Itoa(learned.Matches),
+			strconv.Itoa(learned.Samples),
+			fmt.Sprintf("%.0f%%", float64(learned.Matches)*100/float64(max(learned.Samples, 1))),
+			strconv.FormatBool(learned.Learned),
+		}
+	}
+	return writeRowsToCSV(filePath, patternReportHeaders, rows)
+}
@@ -9,15 +9,16 @@ import (
 	"github.com/tealeg/xlsx"
 )
 
-// ReadRecords reads records from either CSV or XLSX file based on file type
-func ReadRecords(filePath, fileType string) ([]map[string]string, error) {
+// ReadRecords reads records from either CSV or XLSX file based on file type,
+// along with the schema of their columns
+func ReadRecords(filePath, fileType string) (*Schema, []Record, error) {
 	switch strings.ToLower(fileType) {
 	case "csv":
 		return readRecordsFromCSV(filePath)
 	case "xlsx":
 		return readRecordsFromXLSX(filePath)
 	default:
-		return nil, fmt.Errorf("unsupported file type: %s", fileType)
+		return nil, nil, fmt.Errorf("unsupported file type: %s", fileType)
 	}
 }
 
@@ -31,100 +32,67 @@ func normalizeHeaders(headers []string) []string {
 }
 
 // readRecordsFromCSV reads records from a CSV file
-func readRecordsFromCSV(filePath string) ([]map[string]string, error) {
+func readRecordsFromCSV(filePath string) (*Schema, []Record, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer file.Close()
 
 	reader := csv.NewReader(file)
 	rows, err := reader.ReadAll()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if len(rows) == 0 {
-		return nil, fmt.Errorf("empty CSV file: %s", filePath)
+		return nil, nil, fmt.Errorf("empty CSV file: %s", filePath)
 	}
 
-	// Get original headers for preserving case in output
-	originalHeaders := rows[0]
+	// The header row gives the columns in their original order and case
+	schema := NewSchema(rows[0])
 
-	// Normalize headers for case-insensitive matching
-	normalizedHeaders := normalizeHeaders(originalHeaders)
-
-	records := make([]map[string]string, 0, len(rows)-1)
+	records := make([]Record, 0, len(rows)-1)
 	for _, row := range rows[1:] {
-		record := make(map[string]string)
-		for i, value := range row {
-			if i < len(normalizedHeaders) {
-				// Store with lowercase key for consistent access
-				record[normalizedHeaders[i]] = value
-
-				// Also store with original case for backward compatibility
-				if originalHeaders[i] != normalizedHeaders[i] {
-					record[originalHeaders[i]] = value
-				}
-			}
-		}
-		records = append(records, record)
+		records = append(records, newRecord(schema, row))
 	}
-	return records, nil
+	return schema, records, nil
 }
 
 // readRecordsFromXLSX reads records from an XLSX file
-func readRecordsFromXLSX(filePath string) ([]map[string]string, error) {
+func readRecordsFromXLSX(filePath string) (*Schema, []Record, error) {
 	xlFile, err := xlsx.OpenFile(filePath)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if len(xlFile.Sheets) == 0 {
-		return nil, fmt.Errorf("no sheets found in XLSX file: %s", filePath)
+		return nil, nil, fmt.Errorf("no sheets found in XLSX file: %s", filePath)
 	}
 
 	sheet := xlFile.Sheets[0] // Assuming data is in the first sheet
 	if sheet == nil {
-		return nil, fmt.Errorf("sheet not found in XLSX file")
+		return nil, nil, fmt.Errorf("sheet not found in XLSX file")
 	}
 
 	if len(sheet.Rows) == 0 {
-		return nil, fmt.Errorf("empty sheet in XLSX file: %s", filePath)
+		return nil, nil, fmt.Errorf("empty sheet in XLSX file: %s", filePath)
 	}
 
-	// Get original headers for preserving case in output
-	originalHeaders := make([]string, 0)
-	if len(sheet.Rows) > 0 && len(sheet.Rows[0].Cells) > 0 {
-		for _, cell := range sheet.Rows[0].Cells {
-			originalHeaders = append(originalHeaders, cell.Value)
-		}
+	// The header row gives the columns in their original order and case
+	headers := make([]string, 0, len(sheet.Rows[0].Cells))
+	for _, cell := range sheet.Rows[0].Cells {
+		headers = append(headers, cell.Value)
 	}
+	schema := NewSchema(headers)
 
-	// Normalize headers for case-insensitive matching
-	normalizedHeaders := normalizeHeaders(originalHeaders)
-
-	records := make([]map[string]string, 0)
-	for rowIndex, row := range sheet.Rows {
-		if rowIndex == 0 { // Skip header row
-			continue
-		}
-
-		record := make(map[string]string)
+	records := make([]Record, 0, len(sheet.Rows)-1)
+	for _, row := range sheet.Rows[1:] {
+		values := make([]string, len(row.Cells))
 		for cellIndex, cell := range row.Cells {
-			if cellIndex < len(normalizedHeaders) {
-				value := cell.Value
-
-				// Store with lowercase key for consistent access
-				record[normalizedHeaders[cellIndex]] = value
-
-				// Also store with original case for backward compatibility
-				if originalHeaders[cellIndex] != normalizedHeaders[cellIndex] {
-					record[originalHeaders[cellIndex]] = value
-				}
-			}
+			values[cellIndex] = cell.Value
 		}
-		records = append(records, record)
+		records = append(records, newRecord(schema, values))
 	}
-	return records, nil
+	return schema, records, nil
 }
@@ -0,0 +1,121 @@
+package io
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Schema is the ordered list of columns of an input file, as named in its
+// header row
+type Schema struct {
+	headers []string
+	index   map[string]int // normalized header -> first column with that name
+}
+
+// NewSchema creates a schema from a header row
+func NewSchema(headers []string) *Schema {
+	s := &Schema{
+		headers: headers,
+		index:   make(map[string]int, len(headers)),
+	}
+	for i, header := range normalizeHeaders(headers) {
+		if _, exists := s.index[header]; !exists {
+			s.index[header] = i
+		}
+	}
+	return s
+}
+
+// Headers returns the column headers in their original order and case
+func (s *Schema) Headers() []string {
+	return s.headers
+}
+
+// Index returns the position of a column, matching its name case-insensitively
+func (s *Schema) Index(name string) (int, bool) {
+	i, ok := s.index[strings.ToLower(strings.TrimSpace(name))]
+	return i, ok
+}
+
+// Has reports whether the schema has a column, matching its name
+// case-insensitively
+func (s *Schema) Has(name string) bool {
+	_, ok := s.Index(name)
+	return ok
+}
+
+// EmailColumns returns the positions of the named email columns, matching
+// names case-insensitively. With no names, it picks the column named "email",
+// or else every column whose values in the sample mostly look like email
+// addresses.
+func (s *Schema) EmailColumns(names []string, sample []Record) ([]int, error) {
+	var columns []int
+	for _, name := range names {
+		i, ok := s.Index(name)
+		if !ok {
+			return nil, fmt.Errorf("email column %q not found in input headers", name)
+		}
+		columns = append(columns, i)
+	}
+	if len(columns) > 0 {
+		return columns, nil
+	}
+
+	if i, ok := s.Index("email"); ok {
+		return []int{i}, nil
+	}
+
+	for i := range s.headers {
+		values, addresses := 0, 0
+		for _, record := range sample {
+			value := strings.TrimSpace(record.Values[i])
+			if value == "" {
+				continue
+			}
+			values++
+			if looksLikeEmail(value) {
+				addresses++
+			}
+		}
+		if addresses > 0 && addresses*10 >= values*8 {
+			columns = append(columns, i)
+		}
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no email column found: name one with email_columns or -email-columns")
+	}
+	return columns, nil
+}
+
+// looksLikeEmail reports whether a value has the shape of an email address
+func looksLikeEmail(value string) bool {
+	at := strings.LastIndex(value, "@")
+	if at <= 0 || strings.ContainsAny(value, " ,;<>") {
+		return false
+	}
+	domain := value[at+1:]
+	return strings.Contains(domain, ".") && !strings.HasPrefix(domain, ".") && !strings.HasSuffix(domain, ".")
+}
+
+// Record is a data row of an input file, holding a value for every column
+// of its schema
+type Record struct {
+	Schema *Schema
+	Values []string
+}
+
+// newRecord pads or truncates the values of a row to the columns of the schema
+func newRecord(schema *Schema, values []string) Record {
+	record := Record{Schema: schema, Values: make([]string, len(schema.headers))}
+	copy(record.Values, values)
+	return record
+}
+
+// Get returns the value of a column, matching its name case-insensitively,
+// or "" if the schema has no such column
+func (r Record) Get(name string) string {
+	if i, ok := r.Schema.Index(name); ok {
+		return r.Values[i]
+	}
+	return ""
+}
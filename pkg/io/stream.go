@@ -0,0 +1,179 @@
+package io
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Row is an input record with its position among the data rows of the file
+type Row struct {
+	Index  int
+	Record Record
+}
+
+// CSVRowReader reads a CSV file one record at a time, so files of any size
+// can be processed in bounded memory
+type CSVRowReader struct {
+	file   *os.File
+	reader *csv.Reader
+	schema *Schema
+	next   int
+	peeked []Row
+}
+
+// NewCSVRowReader opens a CSV file and reads its header row
+func NewCSVRowReader(filePath string) (*CSVRowReader, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(file)
+	headers, err := reader.Read()
+	if err == io.EOF {
+		file.Close()
+		return nil, fmt.Errorf("empty CSV file: %s", filePath)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &CSVRowReader{file: file, reader: reader, schema: NewSchema(headers)}, nil
+}
+
+// Schema returns the columns of the file
+func (r *CSVRowReader) Schema() *Schema {
+	return r.schema
+}
+
+// Peek returns up to n upcoming rows without consuming them, e.g. to detect
+// the email columns
+func (r *CSVRowReader) Peek(n int) ([]Row, error) {
+	for len(r.peeked) < n {
+		row, err := r.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.peeked = append(r.peeked, row)
+	}
+	if len(r.peeked) < n {
+		n = len(r.peeked)
+	}
+	return r.peeked[:n], nil
+}
+
+// Next returns the next row, or io.EOF once the file is exhausted
+func (r *CSVRowReader) Next() (Row, error) {
+	if len(r.peeked) > 0 {
+		row := r.peeked[0]
+		r.peeked = r.peeked[1:]
+		return row, nil
+	}
+	return r.read()
+}
+
+// read reads the next row from the file
+func (r *CSVRowReader) read() (Row, error) {
+	values, err := r.reader.Read()
+	if err != nil {
+		return Row{}, err
+	}
+
+	row := Row{Index: r.next, Record: newRecord(r.schema, values)}
+	r.next++
+	return row, nil
+}
+
+// Close closes the underlying file
+func (r *CSVRowReader) Close() error {
+	return r.file.Close()
+}
+
+// CSVRowWriter writes verified rows to a CSV file as they are produced
+type CSVRowWriter struct {
+	file   *os.File
+	writer *csv.Writer
+}
+
+// NewCSVRowWriter creates the output file and writes the header row: the
+// columns of the schema followed by the verification columns of the email
+// columns
+func NewCSVRowWriter(filePath string, schema *Schema, emailColumns []int) (*CSVRowWriter, error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write(getOutputHeaders(schema, emailColumns)); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &CSVRowWriter{file: file, writer: writer}, nil
+}
+
+// Write writes a row with its verification results
+func (w *CSVRowWriter) Write(row Row, result RowResult) error {
+	return w.writer.Write(getCSVRow(row.Record, result))
+}
+
+// Close flushes the buffered rows and closes the file
+func (w *CSVRowWriter) Close() error {
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// ReorderBuffer puts rows verified out of order back in input order, holding
+// each one until every row before it has been written
+type ReorderBuffer struct {
+	write   func(Row, RowResult) error
+	next    int
+	pending map[int]reorderEntry
+}
+
+type reorderEntry struct {
+	row    Row
+	result RowResult
+}
+
+// NewReorderBuffer creates a buffer that passes rows to write in index order,
+// starting from index 0
+func NewReorderBuffer(write func(Row, RowResult) error) *ReorderBuffer {
+	return &ReorderBuffer{write: write, pending: make(map[int]reorderEntry)}
+}
+
+// Add queues a verified row and writes every row that is now in order. It
+// returns how many rows were written.
+func (b *ReorderBuffer) Add(row Row, result RowResult) (int, error) {
+	b.pending[row.Index] = reorderEntry{row: row, result: result}
+
+	written := 0
+	for {
+		entry, ok := b.pending[b.next]
+		if !ok {
+			return written, nil
+		}
+		if err := b.write(entry.row, entry.result); err != nil {
+			return written, err
+		}
+		delete(b.pending, b.next)
+		written++
+		b.next++
+	}
+}
+
+// Pending returns the number of rows held back waiting for an earlier one
+func (b *ReorderBuffer) Pending() int {
+	return len(b.pending)
+}
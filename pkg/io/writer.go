@@ -3,113 +3,223 @@ package io
 import (
 	"encoding/csv"
 	"fmt"
-	"log"
 	"os"
-	"sort"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/clau/email_verifier/pkg/verifier"
 	"github.com/tealeg/xlsx"
 )
 
-// WriteResults writes verification results to either CSV or XLSX file
-func WriteResults(filePath, fileType string, records []map[string]string, results []verifier.Result) error {
+// RowResult is what is written for a row: a result per email column and, if
+// the address in the first email column already appeared in an earlier row,
+// that row's number
+type RowResult struct {
+	Results     []verifier.Result
+	DuplicateOf int // row number in the file, counting the header as row 1; 0 if none
+}
+
+// WriteResults writes verification results to either CSV or XLSX file. The
+// columns of the schema come first, in their original order and case,
+// followed by the verification columns for the first email column, the
+// duplicate column and a status/score pair for each other email column.
+// results holds the result of each record.
+func WriteResults(filePath, fileType string, schema *Schema, emailColumns []int, records []Record, results []RowResult) error {
 	switch strings.ToLower(fileType) {
 	case "csv":
-		return writeResultsToCSV(filePath, records, results)
+		return writeResultsToCSV(filePath, schema, emailColumns, records, results)
 	case "xlsx":
-		return writeResultsToXLSX(filePath, records, results)
+		return writeResultsToXLSX(filePath, schema, emailColumns, records, results)
 	default:
 		return fmt.Errorf("unsupported file type: %s", fileType)
 	}
 }
 
-// getHeaders extracts all unique headers from all records
-func getHeaders(records []map[string]string) []string {
-	// Use a map to track all unique keys across all records
-	headerMap := make(map[string]bool)
+// verificationColumn is a result column appended after the original fields
+type verificationColumn struct {
+	header string // lowercase
+	value  func(result verifier.Result) string
+}
+
+// verificationDetails are the detail columns that follow verification status
+// and confidence score, in output order
+var verificationDetails = []verificationColumn{
+	{"syntax valid", checksColumn(func(c *verifier.Checks) string { return strconv.FormatBool(c.SyntaxValid) })},
+	{"has mx records", checksColumn(func(c *verifier.Checks) string { return strconv.FormatBool(c.HasMxRecords) })},
+	{"reachable", checksColumn(func(c *verifier.Checks) string { return c.Reachable })},
+	{"disposable", checksColumn(func(c *verifier.Checks) string { return strconv.FormatBool(c.Disposable) })},
+	{"role account", checksColumn(func(c *verifier.Checks) string { return strconv.FormatBool(c.RoleAccount) })},
+	{"free provider", checksColumn(func(c *verifier.Checks) string { return strconv.FormatBool(c.FreeProvider) })},
+	{"catch all", checksColumn(func(c *verifier.Checks) string { return strconv.FormatBool(c.CatchAll) })},
+	{"greylisted", checksColumn(func(c *verifier.Checks) string { return strconv.FormatBool(c.Greylisted) })},
+	{"smtputf8", checksColumn(func(c *verifier.Checks) string { return strconv.FormatBool(c.SMTPUTF8) })},
+	{"mx hosts", func(r verifier.Result) string { return strings.Join(r.MXHosts, ";") }},
+	{"smtp code", func(r verifier.Result) string {
+		if r.SMTPCode == 0 {
+			return ""
+		}
+		return strconv.Itoa(r.SMTPCode)
+	}},
+	{"smtp message", func(r verifier.Result) string { return r.SMTPMessage }},
+	{"suggestion", func(r verifier.Result) string { return r.Suggestion }},
+	{"reasons", func(r verifier.Result) string { return getReasons(r.Reasons) }},
+	{"error category", func(r verifier.Result) string { return string(r.ErrorCategory) }},
+	{"normalized email", func(r verifier.Result) string { return r.NormalizedEmail }},
+	{"has subaddress", func(r verifier.Result) string {
+		if r.NormalizedEmail == "" {
+			return ""
+		}
+		return strconv.FormatBool(r.HasSubaddress)
+	}},
+	{"email ascii", func(r verifier.Result) string { return r.EmailASCII }},
+	{"email unicode", func(r verifier.Result) string { return r.EmailUnicode }},
+	{"domain match", leadColumn(func(l *verifier.LeadChecks) string { return l.DomainMatch })},
+	{"name pattern", leadColumn(func(l *verifier.LeadChecks) string { return l.NamePattern })},
+	{"spf", policyColumn("spf", func(p *verifier.MailPolicies) string {
+		if p.SPF == nil {
+			return ""
+		}
+		return p.SPF.Record
+	})},
+	{"dmarc policy", policyColumn("dmarc", func(p *verifier.MailPolicies) string {
+		if p.DMARC == nil {
+			return ""
+		}
+		return p.DMARC.Policy
+	})},
+	{"dmarc rua", policyColumn("", func(p *verifier.MailPolicies) string {
+		if p.DMARC == nil {
+			return ""
+		}
+		return strings.Join(p.DMARC.RUA, ";")
+	})},
+	{"mta sts id", policyColumn("mta_sts", func(p *verifier.MailPolicies) string {
+		if p.MTASTS == nil {
+			return ""
+		}
+		return p.MTASTS.ID
+	})},
+	{"tls rpt rua", policyColumn("tls_rpt", func(p *verifier.MailPolicies) string {
+		if p.TLSRPT == nil {
+			return ""
+		}
+		return strings.Join(p.TLSRPT.RUA, ";")
+	})},
+	{"mail route", checksColumn(func(c *verifier.Checks) string { return c.MailRoute })},
+}
 
-	// Collect all unique keys from all records (using lowercase for consistency)
-	for _, record := range records {
-		for key := range record {
-			// Store lowercase version of the key
-			headerMap[strings.ToLower(key)] = true
+// verificationHeaders are the result columns appended after the original
+// fields (lowercase)
+var verificationHeaders = func() []string {
+	headers := []string{"verification status", "confidence score"}
+	for _, column := range verificationDetails {
+		headers = append(headers, column.header)
+	}
+	return headers
+}()
+
+// checksColumn returns the value of a column taken from the checks, empty
+// when the result has none
+func checksColumn(value func(c *verifier.Checks) string) func(verifier.Result) string {
+	return func(r verifier.Result) string {
+		if r.Checks == nil {
+			return ""
 		}
+		return value(r.Checks)
 	}
+}
 
-	// Convert map keys to a slice
-	headers := make([]string, 0, len(headerMap))
-	for key := range headerMap {
-		headers = append(headers, key)
+// leadColumn returns the value of a column taken from the lead checks, empty
+// when the record has none
+func leadColumn(value func(l *verifier.LeadChecks) string) func(verifier.Result) string {
+	return func(r verifier.Result) string {
+		if r.Lead == nil {
+			return ""
+		}
+		return value(r.Lead)
 	}
+}
 
-	// Sort headers alphabetically for consistency, but ensure 'email' is first if present
-	sort.Slice(headers, func(i, j int) bool {
-		if headers[i] == "email" {
-			return true
+// policyColumn returns the value of a column taken from the domain's mail
+// policies. It is "none" when the domain was found not to publish the
+// policy named missing, and empty when the policies are unknown.
+func policyColumn(missing string, value func(p *verifier.MailPolicies) string) func(verifier.Result) string {
+	return func(r verifier.Result) string {
+		if r.DomainInfo == nil {
+			return ""
 		}
-		if headers[j] == "email" {
-			return false
+		if missing != "" && slices.Contains(r.DomainInfo.Missing(), missing) {
+			return "none"
 		}
-		return headers[i] < headers[j]
-	})
+		return value(r.DomainInfo)
+	}
+}
+
+// getVerificationDetails returns the values of the detail columns that follow
+// verification status and confidence score
+func getVerificationDetails(result verifier.Result) []string {
+	details := make([]string, len(verificationDetails))
+	for i, column := range verificationDetails {
+		details[i] = column.value(result)
+	}
+	return details
+}
+
+// getReasons formats reason codes with their score weights, e.g. "ROLE_ACCOUNT:-15;FREE_PROVIDER:-10"
+func getReasons(reasons []verifier.Reason) string {
+	parts := make([]string, len(reasons))
+	for i, reason := range reasons {
+		parts[i] = fmt.Sprintf("%s:%d", reason.Code, reason.Weight)
+	}
+	return strings.Join(parts, ";")
+}
+
+// getOutputHeaders returns all original headers plus verification result
+// headers: the full set for the first email column, then a status/score pair
+// named after each other email column
+func getOutputHeaders(schema *Schema, emailColumns []int) []string {
+	originalHeaders := schema.Headers()
+	headers := appendHeaders(originalHeaders, verificationHeaders)
+
+	headers = append(headers, "duplicate of row")
+	for _, column := range emailColumns[1:] {
+		name := strings.TrimSpace(originalHeaders[column])
+		headers = append(headers, name+" verification status", name+" confidence score")
+	}
 
 	return headers
 }
 
-// getOutputHeaders returns all original headers plus verification result headers
-func getOutputHeaders(originalHeaders []string) []string {
+// appendHeaders returns the original headers followed by the result
+// headers, renaming a result header that an original one already uses
+func appendHeaders(originalHeaders, resultHeaders []string) []string {
 	// Create a copy of the original headers
-	headers := make([]string, len(originalHeaders))
+	headers := make([]string, len(originalHeaders), len(originalHeaders)+len(resultHeaders))
 	copy(headers, originalHeaders)
 
-	// Add verification result headers (lowercase)
-	verificationHeaders := []string{
-		"verification status",
-		"confidence score",
-	}
-
 	// Check if these headers already exist in the original data
-	for _, vh := range verificationHeaders {
+	for _, rh := range resultHeaders {
 		exists := false
-		for _, h := range headers {
-			if strings.ToLower(h) == vh {
+		for _, h := range originalHeaders {
+			if strings.EqualFold(strings.TrimSpace(h), rh) {
 				exists = true
 				break
 			}
 		}
 
 		if !exists {
-			headers = append(headers, vh)
+			headers = append(headers, rh)
 		} else {
 			// If header already exists, use a modified name to avoid collision
-			headers = append(headers, vh+" (verification)")
+			headers = append(headers, rh+" (verification)")
 		}
 	}
-
 	return headers
 }
 
-// getLowercaseValue gets a value from a record using case-insensitive key matching
-func getLowercaseValue(record map[string]string, key string) string {
-	// First try direct lookup with the lowercase key
-	if value, exists := record[key]; exists {
-		return value
-	}
-
-	// If not found, try case-insensitive lookup
-	lowercaseKey := strings.ToLower(key)
-	for k, v := range record {
-		if strings.ToLower(k) == lowercaseKey {
-			return v
-		}
-	}
-
-	return "" // Return empty string if not found
-}
-
 // writeResultsToCSV writes the verification results to a CSV file
-func writeResultsToCSV(filePath string, records []map[string]string, results []verifier.Result) error {
+func writeResultsToCSV(filePath string, schema *Schema, emailColumns []int, records []Record, results []RowResult) error {
 	file, err := os.Create(filePath)
 	if err != nil {
 		return err
@@ -119,88 +229,67 @@ func writeResultsToCSV(filePath string, records []map[string]string, results []v
 	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
-	// Get all unique headers from records
-	originalHeaders := getHeaders(records)
-
-	// Get output headers including verification results
-	outputHeaders := getOutputHeaders(originalHeaders)
-
-	// Write header row (all lowercase)
-	lowercaseHeaders := make([]string, len(outputHeaders))
-	for i, header := range outputHeaders {
-		lowercaseHeaders[i] = strings.ToLower(header)
-	}
-
-	if err := writer.Write(lowercaseHeaders); err != nil {
+	// Write header row
+	if err := writer.Write(getOutputHeaders(schema, emailColumns)); err != nil {
 		return err
 	}
 
 	// Write data rows
 	for i, record := range records {
-		// Create a slice for the row with all fields
-		row := make([]string, len(outputHeaders))
-
-		// Add original record fields
-		for j, header := range originalHeaders {
-			row[j] = getLowercaseValue(record, header)
+		if err := writer.Write(getCSVRow(record, results[i])); err != nil {
+			return err
 		}
+	}
 
-		// Add verification results
-		var result verifier.Result
-		email := getLowercaseValue(record, "email")
-		email = strings.TrimSpace(email)
-
-		if i < len(results) && strings.EqualFold(email, results[i].Email) {
-			result = results[i]
-		} else {
-			// Try to find matching result by email (case-insensitive)
-			found := false
-			for _, r := range results {
-				if strings.EqualFold(email, r.Email) {
-					result = r
-					found = true
-					break
-				}
-			}
-
-			if !found {
-				log.Printf("Warning: No matching result found for email: %s. Setting to invalid.", email)
-				result = verifier.Result{Email: email, VerificationStatus: "invalid", ConfidenceScore: 0}
-			}
-		}
+	return nil
+}
 
-		// Add verification status and confidence score at the end
-		verificationStartIdx := len(originalHeaders)
-		row[verificationStartIdx] = result.VerificationStatus
-		row[verificationStartIdx+1] = fmt.Sprintf("%d", result.ConfidenceScore)
+// getCSVRow returns the original record fields followed by the verification
+// status, confidence score and details of the first result, the duplicate
+// column, then the status and score of the other results
+func getCSVRow(record Record, result RowResult) []string {
+	row := make([]string, 0, len(record.Values)+len(verificationHeaders)+1+2*len(result.Results))
+	row = append(row, record.Values...)
+	row = append(row, getStatusAndScore(result.Results[0])...)
+	row = append(row, getVerificationDetails(result.Results[0])...)
+	row = append(row, getDuplicateOf(result))
+	for _, other := range result.Results[1:] {
+		row = append(row, getStatusAndScore(other)...)
+	}
+	return row
+}
 
-		if err := writer.Write(row); err != nil {
-			return err
-		}
+// getDuplicateOf returns the row number a duplicate row repeats, or "" for
+// the first row with its address
+func getDuplicateOf(result RowResult) string {
+	if result.DuplicateOf == 0 {
+		return ""
 	}
+	return strconv.Itoa(result.DuplicateOf)
+}
 
-	return nil
+// getStatusAndScore returns the verification status and confidence score of
+// a result, both empty when there was no address to verify
+func getStatusAndScore(result verifier.Result) []string {
+	if result.VerificationStatus == "" {
+		return []string{"", ""}
+	}
+	return []string{result.VerificationStatus, strconv.Itoa(result.ConfidenceScore)}
 }
 
 // writeResultsToXLSX writes the verification results to an Excel file
-func writeResultsToXLSX(filePath string, records []map[string]string, results []verifier.Result) error {
+func writeResultsToXLSX(filePath string, schema *Schema, emailColumns []int, records []Record, results []RowResult) error {
 	file := xlsx.NewFile()
 	sheet, err := file.AddSheet("verified leads")
 	if err != nil {
 		return err
 	}
 
-	// Get all unique headers from records
-	originalHeaders := getHeaders(records)
-
-	// Get output headers including verification results
-	outputHeaders := getOutputHeaders(originalHeaders)
-
-	// Write header row (all lowercase)
+	// Write header row
 	headerRow := sheet.AddRow()
-	for _, header := range outputHeaders {
+	for _, header := range getOutputHeaders(schema, emailColumns) {
 		headerCell := headerRow.AddCell()
-		headerCell.SetString(strings.ToLower(header))
+		headerCell.SetString(header)
 	}
 
 	// Write data rows
@@ -208,41 +297,42 @@ func writeResultsToXLSX(filePath string, records []map[string]string, results []
 		row := sheet.AddRow()
 
 		// Add original record fields
-		for _, header := range originalHeaders {
+		for _, value := range record.Values {
 			cell := row.AddCell()
-			cell.SetString(getLowercaseValue(record, header))
+			cell.SetString(value)
 		}
 
-		// Add verification results
-		var result verifier.Result
-		email := getLowercaseValue(record, "email")
-		email = strings.TrimSpace(email)
-
-		if i < len(results) && strings.EqualFold(email, results[i].Email) {
-			result = results[i]
-		} else {
-			// Try to find matching result by email (case-insensitive)
-			found := false
-			for _, r := range results {
-				if strings.EqualFold(email, r.Email) {
-					result = r
-					found = true
-					break
-				}
-			}
+		// Add verification status, confidence score and details
+		result := results[i].Results[0]
+		addStatusAndScore(row, result)
+		for _, detail := range getVerificationDetails(result) {
+			detailCell := row.AddCell()
+			detailCell.SetString(detail)
+		}
 
-			if !found {
-				log.Printf("Warning: No matching result found for email: %s. Setting to invalid.", email)
-				result = verifier.Result{Email: email, VerificationStatus: "invalid", ConfidenceScore: 0}
-			}
+		// Add the row this one duplicates
+		duplicateCell := row.AddCell()
+		if results[i].DuplicateOf != 0 {
+			duplicateCell.SetInt(results[i].DuplicateOf)
 		}
 
-		// Add verification status and confidence score
-		statusCell := row.AddCell()
-		statusCell.SetString(result.VerificationStatus)
-		scoreCell := row.AddCell()
-		scoreCell.SetInt(result.ConfidenceScore)
+		// Add the status and score of the other email columns
+		for _, other := range results[i].Results[1:] {
+			addStatusAndScore(row, other)
+		}
 	}
 
 	return file.Save(filePath)
 }
+
+// addStatusAndScore adds cells with the verification status and confidence
+// score of a result, both empty when there was no address to verify
+func addStatusAndScore(row *xlsx.Row, result verifier.Result) {
+	statusCell := row.AddCell()
+	scoreCell := row.AddCell()
+	if result.VerificationStatus == "" {
+		return
+	}
+	statusCell.SetString(result.VerificationStatus)
+	scoreCell.SetInt(result.ConfidenceScore)
+}
@@ -0,0 +1,112 @@
+package verifier
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultDomainCacheTTL is how long domain facts are kept when the config
+// leaves it empty
+const defaultDomainCacheTTL = time.Hour
+
+// DomainInfo holds the facts shared by every address at a domain
+type DomainInfo struct {
+	HasMxRecords bool
+	MXHosts      []string
+	MailRoute    string // mx, implicit_mx, null_mx or none
+	Disposable   bool
+	Free         bool
+	Suggestion   string
+	Policies     *MailPolicies
+	CatchAll     *bool // nil until an SMTP probe has found out
+}
+
+// domainEntry is a cached DomainInfo; ready is closed once it has loaded
+type domainEntry struct {
+	ready   chan struct{}
+	info    DomainInfo
+	err     error
+	expires time.Time
+}
+
+// domainCache shares domain facts between workers so each domain is looked
+// up once per TTL, however many addresses it has
+type domainCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*domainEntry
+	hits    int
+	misses  int
+}
+
+func newDomainCache(ttl time.Duration) *domainCache {
+	if ttl <= 0 {
+		ttl = defaultDomainCacheTTL
+	}
+	return &domainCache{
+		ttl:     ttl,
+		entries: make(map[string]*domainEntry),
+	}
+}
+
+// get returns the facts for a domain, calling load on a miss. Concurrent
+// callers for the same domain wait for a single load. Failed loads are not
+// cached.
+func (c *domainCache) get(domain string, load func() (DomainInfo, error)) (DomainInfo, error) {
+	domain = strings.ToLower(domain)
+
+	c.mu.Lock()
+	if e, ok := c.entries[domain]; ok && (e.expires.IsZero() || time.Now().Before(e.expires)) {
+		c.hits++
+		c.mu.Unlock()
+		<-e.ready
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return e.info, e.err
+	}
+	e := &domainEntry{ready: make(chan struct{})}
+	c.entries[domain] = e
+	c.misses++
+	c.mu.Unlock()
+
+	info, err := load()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e.info, e.err = info, err
+	e.expires = time.Now().Add(c.ttl)
+	if err != nil && c.entries[domain] == e {
+		delete(c.entries, domain)
+	}
+	close(e.ready)
+	return info, err
+}
+
+// learn records whether a domain is a catch-all once a probe has found out:
+// either it accepted the random mailbox, or it accepted the target and
+// rejected the random one
+func (c *domainCache) learn(domain string, probe *ProbeResult) {
+	if probe == nil || (!probe.CatchAll && probe.Reachable != "yes") {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[strings.ToLower(domain)]; ok {
+		select {
+		case <-e.ready:
+			catchAll := probe.CatchAll
+			e.info.CatchAll = &catchAll
+		default:
+		}
+	}
+}
+
+// stats returns the number of lookups served from the cache and loaded
+func (c *domainCache) stats() (hits, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
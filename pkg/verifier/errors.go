@@ -0,0 +1,125 @@
+package verifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"syscall"
+)
+
+// ErrorCategory classifies why a verification failed
+type ErrorCategory string
+
+// Error categories reported in Result.ErrorCategory
+const (
+	CategoryDNS               ErrorCategory = "dns"
+	CategorySMTPTemporary     ErrorCategory = "smtp_temporary"
+	CategorySMTPPermanent     ErrorCategory = "smtp_permanent"
+	CategoryTimeout           ErrorCategory = "timeout"
+	CategoryConnectionRefused ErrorCategory = "connection_refused"
+	CategoryNetwork           ErrorCategory = "network"
+	CategoryPanic             ErrorCategory = "panic"
+	CategoryCanceled          ErrorCategory = "canceled"
+	CategoryUnknown           ErrorCategory = "unknown"
+)
+
+// Error is a verification failure tagged with its category
+type Error struct {
+	Category ErrorCategory
+	Err      error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %v", e.Category, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether trying again may succeed
+func (e *Error) Retryable() bool {
+	switch e.Category {
+	case CategorySMTPTemporary, CategoryTimeout, CategoryConnectionRefused, CategoryNetwork:
+		return true
+	case CategoryDNS:
+		// A domain that does not exist will not exist on the next attempt
+		var dnsErr *net.DNSError
+		return errors.As(e.Err, &dnsErr) && !dnsErr.IsNotFound && (dnsErr.IsTemporary || dnsErr.IsTimeout)
+	default:
+		return false
+	}
+}
+
+// classifyError wraps err in an *Error carrying its category
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var verr *Error
+	if errors.As(err, &verr) {
+		return err
+	}
+	return &Error{Category: categoryOf(err), Err: err}
+}
+
+// categoryOf determines the category of an error from its type
+func categoryOf(err error) ErrorCategory {
+	if errors.Is(err, context.Canceled) {
+		return CategoryCanceled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return CategoryTimeout
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return CategoryDNS
+	}
+
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		if isTemporaryReply(protoErr.Code) {
+			return CategorySMTPTemporary
+		}
+		return CategorySMTPPermanent
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return CategoryTimeout
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return CategoryConnectionRefused
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return CategoryNetwork
+	}
+
+	return CategoryUnknown
+}
+
+// isRetryableError checks if an error is considered retryable
+func isRetryableError(err error) bool {
+	var verr *Error
+	return errors.As(err, &verr) && verr.Retryable()
+}
+
+// ErrorCategoryOf returns the category of a verification error, or an empty
+// category for nil
+func ErrorCategoryOf(err error) ErrorCategory {
+	if err == nil {
+		return ""
+	}
+
+	var verr *Error
+	if errors.As(err, &verr) {
+		return verr.Category
+	}
+	return categoryOf(err)
+}
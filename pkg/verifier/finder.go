@@ -0,0 +1,128 @@
+package verifier
+
+import (
+	"context"
+	"errors"
+
+	emailverifier "github.com/AfterShip/email-verifier"
+)
+
+// ErrIncompleteLead is returned when a lead lacks the name or company domain
+// needed to look for their address
+var ErrIncompleteLead = errors.New("a first or last name and a company domain are needed to find an address")
+
+// FindResult is the outcome of looking for a lead's address
+type FindResult struct {
+	Status          string   `json:"status"` // found, not_found, unknown, catch_all or no_mail
+	Email           string   `json:"email,omitempty"`
+	Pattern         string   `json:"pattern,omitempty"` // the name pattern of the address found
+	ConfidenceScore int      `json:"confidence_score"`
+	Result          *Result  `json:"result,omitempty"` // verification of the address found
+	Tried           []string `json:"tried"`            // the addresses checked, in order
+}
+
+// candidate is an address a name pattern gives for a lead
+type candidate struct {
+	email   string
+	pattern string
+}
+
+// candidates returns the addresses the name patterns give for a lead at
+// their company domain, most common pattern first and without repeats
+func candidates(lead Lead) []candidate {
+	domain := registrableDomain(lead.CompanyDomain)
+	first, last := nameToken(lead.FirstName), nameToken(lead.LastName)
+	if domain == "" || (first == "" && last == "") {
+		return nil
+	}
+
+	var found []candidate
+	seen := make(map[string]bool)
+	for _, pattern := range namePatterns {
+		local := pattern.build(first, last)
+		if local == "" || seen[local] {
+			continue
+		}
+		seen[local] = true
+		found = append(found, candidate{email: local + "@" + domain, pattern: pattern.name})
+	}
+	return found
+}
+
+// Find looks for a lead's address at their company domain. It checks the
+// addresses the common name patterns give, most likely first, over one SMTP
+// session, and stops at the first one the mail server confirms. It does not
+// guess at catch-all domains, which accept every address, or at domains
+// without mail servers. The search is bounded by the verify timeout. If it
+// fails, the result so far is returned with the error.
+func (v *Verifier) Find(ctx context.Context, lead Lead) (*FindResult, error) {
+	candidates := candidates(lead)
+	if len(candidates) == 0 {
+		return nil, ErrIncompleteLead
+	}
+
+	if v.config.VerifyTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.config.VerifyTimeout)
+		defer cancel()
+	}
+
+	// Get a verifier from the pool
+	verifier := v.pool.Get().(*emailverifier.Verifier)
+	defer v.pool.Put(verifier)
+
+	find := &FindResult{Status: "not_found", Tried: []string{}}
+	var lookups []*Lookup
+	var addresses []string
+	for _, c := range candidates {
+		lookup, err := v.checkAttempt(ctx, verifier, c.email)
+		if err != nil {
+			return find, classifyError(err)
+		}
+		lookups = append(lookups, lookup)
+		addresses = append(addresses, lookup.address)
+	}
+
+	// The candidates share a domain, so the first tells whether it takes mail
+	first := lookups[0]
+	if !first.probeable() {
+		if len(first.MXHosts) == 0 {
+			find.Status = "no_mail"
+		}
+		return find, nil
+	}
+
+	probes, err := v.prober.probeUntil(ctx, first.MXHosts, addresses, first.knownCatchAll, func(probe *ProbeResult) bool {
+		return probe.Reachable == "yes" || probe.CatchAll
+	})
+	for k, probe := range probes {
+		if probe == nil {
+			break
+		}
+		c, lookup := candidates[k], lookups[k]
+		find.Tried = append(find.Tried, c.email)
+		lookup.applyProbe(probe)
+		v.domains.learn(lookup.Syntax.Domain, probe)
+
+		switch {
+		case lookup.CatchAll:
+			find.Status = "catch_all"
+			return find, nil
+		case lookup.Reachable == "yes":
+			result := v.CheckLead(v.DetermineStatus(lookup, c.email), lead)
+			find.Status, find.Email, find.Pattern = "found", c.email, c.pattern
+			find.ConfidenceScore, find.Result = result.ConfidenceScore, &result
+			return find, nil
+		case lookup.Reachable == "unknown":
+			// The server would not say; a later address may still be confirmed
+			find.Status = "unknown"
+		}
+	}
+
+	// A server temporarily refusing the session would not say either
+	if isTemporaryError(err) {
+		find.Status = "unknown"
+		return find, nil
+	}
+	return find, classifyError(err)
+}
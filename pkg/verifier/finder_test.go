@@ -0,0 +1,85 @@
+package verifier
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	lead := Lead{FirstName: "Jane", LastName: "Doe", CompanyDomain: "https://www.example.com"}
+	tests := []struct {
+		name     string
+		rcpt     func(string) string
+		status   string
+		email    string
+		tried    []string
+		sessions int
+	}{
+		{
+			name:     "found",
+			rcpt:     replyFor("jdoe@example.com", "250 OK", "550 No such user"),
+			status:   "found",
+			email:    "jdoe@example.com",
+			tried:    []string{"jane.doe@example.com", "jdoe@example.com"},
+			sessions: 1,
+		},
+		{
+			name:     "catch-all",
+			rcpt:     replyFor("jane.doe@example.com", "250 OK", "250 OK"),
+			status:   "catch_all",
+			tried:    []string{"jane.doe@example.com"},
+			sessions: 1,
+		},
+		{
+			name:   "not found",
+			rcpt:   replyFor("", "", "550 No such user"),
+			status: "not_found",
+			tried: []string{
+				"jane.doe@example.com", "jdoe@example.com", "jane@example.com", "janedoe@example.com",
+				"j.doe@example.com", "janed@example.com", "jane_doe@example.com", "jane-doe@example.com",
+				"jane.d@example.com", "doe.jane@example.com", "doejane@example.com", "doej@example.com",
+				"doe@example.com",
+			},
+			sessions: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSMTPServer(t, tt.rcpt)
+			v := newTestVerifier(server, exampleResolver())
+
+			find, err := v.Find(context.Background(), lead)
+			if err != nil {
+				t.Fatalf("Find() error = %v", err)
+			}
+			if find.Status != tt.status || find.Email != tt.email {
+				t.Errorf("Find() = %s %q, want %s %q", find.Status, find.Email, tt.status, tt.email)
+			}
+			if !slices.Equal(find.Tried, tt.tried) {
+				t.Errorf("Find() tried %v, want %v", find.Tried, tt.tried)
+			}
+			if sessions, _ := server.stats(); sessions != tt.sessions {
+				t.Errorf("server saw %d sessions, want %d", sessions, tt.sessions)
+			}
+		})
+	}
+}
+
+func TestFindAtNullMX(t *testing.T) {
+	server := newFakeSMTPServer(t, replyFor("", "", "250 OK"))
+	v := newTestVerifier(server, &FakeResolver{MX: map[string][]*net.MX{"example.com": {{Host: "."}}}})
+
+	find, err := v.Find(context.Background(), Lead{FirstName: "Jane", LastName: "Doe", CompanyDomain: "example.com"})
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if find.Status != "no_mail" || len(find.Tried) != 0 {
+		t.Errorf("Find() = %s after trying %v, want no_mail after trying none", find.Status, find.Tried)
+	}
+	if sessions, _ := server.stats(); sessions != 0 {
+		t.Errorf("server saw %d sessions, want 0", sessions)
+	}
+}
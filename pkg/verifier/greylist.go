@@ -0,0 +1,80 @@
+package verifier
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"sync"
+	"time"
+)
+
+// defaultGreylistDelay is how long greylisted addresses wait before being
+// re-verified when the config leaves it empty
+const defaultGreylistDelay = 5 * time.Minute
+
+// DeferredQueue parks emails whose SMTP check was temporarily rejected
+// (greylisted) until they can be verified again
+type DeferredQueue struct {
+	mu      sync.Mutex
+	delay   time.Duration
+	emails  []string
+	readyAt time.Time
+}
+
+// NewDeferredQueue creates a queue that holds emails for the greylisting delay
+func NewDeferredQueue(delay time.Duration) *DeferredQueue {
+	if delay <= 0 {
+		delay = defaultGreylistDelay
+	}
+	return &DeferredQueue{delay: delay}
+}
+
+// Add parks an email until the greylisting delay has passed
+func (q *DeferredQueue) Add(email string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.emails = append(q.emails, email)
+	q.readyAt = time.Now().Add(q.delay)
+}
+
+// Len returns the number of parked emails
+func (q *DeferredQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.emails)
+}
+
+// Delay returns how long emails are parked for
+func (q *DeferredQueue) Delay() time.Duration {
+	return q.delay
+}
+
+// Drain waits until every parked email is due, then empties the queue and
+// returns them. If ctx is done first, the emails stay parked and ctx's error
+// is returned.
+func (q *DeferredQueue) Drain(ctx context.Context) ([]string, error) {
+	q.mu.Lock()
+	wait := time.Until(q.readyAt)
+	q.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	emails := q.emails
+	q.emails = nil
+	return emails, nil
+}
+
+// isTemporaryError reports whether a mail server temporarily refused the
+// conversation, e.g. a 421 greeting or a 451 reply to MAIL FROM
+func isTemporaryError(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr) && isTemporaryReply(protoErr.Code)
+}
@@ -0,0 +1,109 @@
+package verifier
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	emailverifier "github.com/AfterShip/email-verifier"
+	"golang.org/x/net/idna"
+)
+
+// maxLocalPartLength is the longest local part SMTP allows, in octets
+const maxLocalPartLength = 64
+
+// AddressForms returns an address with its domain in A-labels (punycode), as
+// used for DNS and SMTP, and in U-labels, as people write it. An address
+// whose domain cannot be converted is returned unchanged in both forms.
+func AddressForms(email string) (asciiForm, unicodeForm string) {
+	email = strings.TrimSpace(email)
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return email, email
+	}
+	local, domain := email[:at], email[at+1:]
+
+	asciiDomain, err := toASCIIDomain(domain)
+	if err != nil {
+		return email, email
+	}
+	return local + "@" + asciiDomain, local + "@" + toUnicodeDomain(asciiDomain)
+}
+
+// toASCIIDomain converts a domain to A-labels. ASCII domains are only
+// lowercased, since the IDNA rules reject names such as those with
+// underscores that are still valid in DNS.
+func toASCIIDomain(domain string) (string, error) {
+	domain = strings.TrimSuffix(domain, ".")
+	if isASCII(domain) {
+		return strings.ToLower(domain), nil
+	}
+	return idna.Lookup.ToASCII(domain)
+}
+
+// toUnicodeDomain converts a domain to U-labels, leaving it unchanged if it
+// has none or cannot be converted
+func toUnicodeDomain(domain string) string {
+	if !strings.Contains(domain, "xn--") {
+		return domain
+	}
+	if u, err := idna.Lookup.ToUnicode(domain); err == nil {
+		return u
+	}
+	return domain
+}
+
+// isASCII reports whether s holds only ASCII characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// needsSMTPUTF8 reports whether an address has a non-ASCII local part, which
+// only mail servers advertising SMTPUTF8 can accept (RFC 6531)
+func needsSMTPUTF8(email string) bool {
+	return !isASCII(email[:max(strings.LastIndex(email, "@"), 0)])
+}
+
+// parseAddress checks the syntax of an address whose domain is in A-labels.
+// The AfterShip parser only accepts ASCII, so a non-ASCII local part is
+// checked here against RFC 6531 and the domain by the parser.
+func parseAddress(verifier *emailverifier.Verifier, email string) emailverifier.Syntax {
+	if !needsSMTPUTF8(email) {
+		return verifier.ParseAddress(email)
+	}
+
+	at := strings.LastIndex(email, "@")
+	local, domain := email[:at], email[at+1:]
+	if !verifier.ParseAddress("postmaster@"+domain).Valid || !validLocalPart(local) {
+		return emailverifier.Syntax{}
+	}
+	return emailverifier.Syntax{Username: local, Domain: domain, Valid: true}
+}
+
+// validLocalPart reports whether an internationalized local part is a valid
+// dot-atom: UTF-8 letters, digits and the ASCII atom symbols, separated by
+// single dots
+func validLocalPart(local string) bool {
+	if local == "" || len(local) > maxLocalPartLength || !utf8.ValidString(local) {
+		return false
+	}
+	for _, atom := range strings.Split(local, ".") {
+		if atom == "" {
+			return false
+		}
+		for _, r := range atom {
+			if r < utf8.RuneSelf && !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("!#$%&'*+/=?^_`{|}~-", r) {
+				return false
+			}
+			if r >= utf8.RuneSelf && (!unicode.IsGraphic(r) || unicode.IsSpace(r)) {
+				return false
+			}
+		}
+	}
+	return true
+}
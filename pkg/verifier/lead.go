@@ -0,0 +1,207 @@
+package verifier
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/clau/email_verifier/pkg/utils"
+	"golang.org/x/net/publicsuffix"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Lead is what a lead record says about the person behind an address, used
+// to check that the address fits them
+type Lead struct {
+	CompanyDomain string // the company's domain or website URL
+	FirstName     string
+	LastName      string
+}
+
+// LeadChecks holds the outcome of the checks against the lead record
+type LeadChecks struct {
+	DomainMatch string `json:"domain_match,omitempty"` // match, personal or mismatch
+	NamePattern string `json:"name_pattern,omitempty"` // the name pattern of the local part, or none
+}
+
+// namePattern is a common way of building the local part of an address
+// from a person's name
+type namePattern struct {
+	name  string
+	build func(first, last string) string // "" when a needed part is missing
+}
+
+// namePatterns are the name patterns checked, most common first
+var namePatterns = []namePattern{
+	{"first.last", func(f, l string) string { return join(f, ".", l) }},
+	{"flast", func(f, l string) string { return join(initial(f), "", l) }},
+	{"first", func(f, l string) string { return f }},
+	{"firstlast", func(f, l string) string { return join(f, "", l) }},
+	{"f.last", func(f, l string) string { return join(initial(f), ".", l) }},
+	{"firstl", func(f, l string) string { return join(f, "", initial(l)) }},
+	{"first_last", func(f, l string) string { return join(f, "_", l) }},
+	{"first-last", func(f, l string) string { return join(f, "-", l) }},
+	{"first.l", func(f, l string) string { return join(f, ".", initial(l)) }},
+	{"last.first", func(f, l string) string { return join(l, ".", f) }},
+	{"lastfirst", func(f, l string) string { return join(l, "", f) }},
+	{"lastf", func(f, l string) string { return join(l, "", initial(f)) }},
+	{"last", func(f, l string) string { return l }},
+}
+
+// join joins two name parts, or returns "" if either is missing
+func join(a, sep, b string) string {
+	if a == "" || b == "" {
+		return ""
+	}
+	return a + sep + b
+}
+
+// initial returns the first letter of a name part
+func initial(part string) string {
+	for _, r := range part {
+		return string(r)
+	}
+	return ""
+}
+
+// nameToken reduces a name to the letters and digits used in addresses,
+// without accents, so "O'Brien", "Mary Ann" and "Seán" become "obrien",
+// "maryann" and "sean"
+func nameToken(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, norm.NFD.String(name))
+}
+
+// matchNamePattern returns the name pattern the local part of an address
+// follows, ignoring any plus tag, or "" if it follows none
+func matchNamePattern(email, firstName, lastName string) string {
+	local := strings.ToLower(email[:max(strings.LastIndex(email, "@"), 0)])
+	if i := strings.Index(local, "+"); i > 0 {
+		local = local[:i]
+	}
+	first, last := nameToken(firstName), nameToken(lastName)
+	for _, pattern := range namePatterns {
+		if candidate := pattern.build(first, last); candidate != "" && candidate == local {
+			return pattern.name
+		}
+	}
+	return ""
+}
+
+// relatedDomains maps domains of the same organization to one name, so an
+// address at a parent or sister company's domain matches the company
+var relatedDomains = map[string]string{
+	"google.com":           "google",
+	"alphabet.com":         "google",
+	"youtube.com":          "google",
+	"meta.com":             "meta",
+	"facebook.com":         "meta",
+	"fb.com":               "meta",
+	"instagram.com":        "meta",
+	"microsoft.com":        "microsoft",
+	"linkedin.com":         "microsoft",
+	"github.com":           "microsoft",
+	"amazon.com":           "amazon",
+	"aws.com":              "amazon",
+	"wholefoodsmarket.com": "amazon",
+	"salesforce.com":       "salesforce",
+	"slack.com":            "salesforce",
+	"tableau.com":          "salesforce",
+}
+
+// forcingReasons are the reasons that force a status regardless of score
+var forcingReasons = []ReasonCode{
+	ReasonSyntaxInvalid,
+	ReasonMailboxNotFound,
+	ReasonNullMX,
+	ReasonSMTPUTF8Unsupported,
+	ReasonDisposable,
+	ReasonVerificationFailed,
+}
+
+// CheckLead checks the result of an address against the lead record it came
+// from and rescores it. Results that were not verified, or whose status is
+// forced, are returned unchanged. The result is for the address alone, so it
+// can be cached and shared; the lead checks are applied per record.
+func (v *Verifier) CheckLead(result Result, lead Lead) Result {
+	if result.Checks == nil || result.ErrorCategory != "" || slices.ContainsFunc(result.Reasons, func(r Reason) bool {
+		return slices.Contains(forcingReasons, r.Code)
+	}) {
+		return result
+	}
+
+	// The result may be shared with other records, so extend copies of it
+	checks := &LeadChecks{}
+	result.Reasons = slices.Clip(result.Reasons)
+	score := result.ConfidenceScore
+
+	if company := registrableDomain(lead.CompanyDomain); company != "" {
+		switch domain := registrableDomain(domainOf(result.Email)); {
+		case sameOrganization(domain, company):
+			checks.DomainMatch = "match"
+		case result.Checks.FreeProvider:
+			checks.DomainMatch = "personal"
+			score += result.addReason(ReasonPersonalEmailForBusinessLead, v.config.ScoringWeights.PersonalEmailForBusinessLead)
+		default:
+			checks.DomainMatch = "mismatch"
+			score += result.addReason(ReasonDomainMismatch, v.config.ScoringWeights.DomainMismatch)
+		}
+	}
+
+	if nameToken(lead.FirstName) != "" || nameToken(lead.LastName) != "" {
+		if checks.NamePattern = matchNamePattern(result.Email, lead.FirstName, lead.LastName); checks.NamePattern != "" {
+			score += result.addReason(ReasonNameMatch, v.config.ScoringWeights.NameMatch)
+		} else {
+			checks.NamePattern = "none"
+			score += result.addReason(ReasonNameMismatch, v.config.ScoringWeights.NameMismatch)
+		}
+	}
+
+	if *checks == (LeadChecks{}) {
+		return result
+	}
+	result.Lead = checks
+	result.ConfidenceScore = utils.Max(0, utils.Min(score, 100))
+	result.VerificationStatus = v.status(result.ConfidenceScore)
+	return result
+}
+
+// registrableDomain returns the domain a company registered, from a domain
+// or website URL: without scheme, path, port, "www." or other subdomains,
+// and in A-labels. It returns "" if there is no domain.
+func registrableDomain(site string) string {
+	site = strings.TrimSpace(site)
+	if site == "" {
+		return ""
+	}
+	if !strings.Contains(site, "://") {
+		site = "http://" + site
+	}
+	u, err := url.Parse(site)
+	if err != nil {
+		return ""
+	}
+	host, err := toASCIIDomain(u.Hostname())
+	if err != nil || host == "" {
+		return ""
+	}
+	if registrable, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return registrable
+	}
+	return strings.TrimPrefix(host, "www.")
+}
+
+// sameOrganization reports whether two registrable domains are the same or
+// belong to the same organization
+func sameOrganization(a, b string) bool {
+	if a == b {
+		return true
+	}
+	org, ok := relatedDomains[a]
+	return ok && org == relatedDomains[b]
+}
@@ -0,0 +1,64 @@
+package verifier
+
+import "strings"
+
+// mailProvider describes how a mail provider maps addresses to mailboxes
+type mailProvider struct {
+	domain     string // canonical domain of the provider
+	ignoreDots bool   // dots in the local part are not significant
+	plusTags   bool   // "local+tag" is delivered to the mailbox "local"
+}
+
+// mailProviders lists the providers whose address aliases are known, by
+// domain. Addresses at other domains are only case-folded, since their
+// servers may treat dots and plus signs literally.
+var mailProviders = map[string]mailProvider{
+	"gmail.com":      {domain: "gmail.com", ignoreDots: true, plusTags: true},
+	"googlemail.com": {domain: "gmail.com", ignoreDots: true, plusTags: true},
+	"outlook.com":    {domain: "outlook.com", plusTags: true},
+	"hotmail.com":    {domain: "hotmail.com", plusTags: true},
+	"live.com":       {domain: "live.com", plusTags: true},
+	"icloud.com":     {domain: "icloud.com", plusTags: true},
+	"me.com":         {domain: "me.com", plusTags: true},
+	"mac.com":        {domain: "mac.com", plusTags: true},
+	"fastmail.com":   {domain: "fastmail.com", plusTags: true},
+	"protonmail.com": {domain: "protonmail.com", plusTags: true},
+	"proton.me":      {domain: "proton.me", plusTags: true},
+	"pm.me":          {domain: "pm.me", plusTags: true},
+}
+
+// Normalize returns the canonical form of an address, under which aliases of
+// the same mailbox such as John.Doe+promo@googlemail.com and
+// johndoe@gmail.com are equal, and whether the address has a subaddress
+// (plus tag) its provider delivers to the base mailbox
+func Normalize(email string) (normalized string, subaddress bool) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return email, false
+	}
+	local, domain := email[:at], strings.TrimSuffix(email[at+1:], ".")
+	if ascii, err := toASCIIDomain(domain); err == nil {
+		// Unicode and punycode spellings of a domain are the same domain
+		domain = toUnicodeDomain(ascii)
+	}
+
+	provider, ok := mailProviders[domain]
+	if !ok {
+		return local + "@" + domain, false
+	}
+	if i := strings.Index(local, "+"); provider.plusTags && i > 0 {
+		local, subaddress = local[:i], true
+	}
+	if provider.ignoreDots {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	return local + "@" + provider.domain, subaddress
+}
+
+// NormalizeEmail returns the canonical form of an address, used to recognize
+// the same mailbox written differently so it is verified and cached once
+func NormalizeEmail(email string) string {
+	normalized, _ := Normalize(email)
+	return normalized
+}
@@ -0,0 +1,152 @@
+package verifier
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/clau/email_verifier/pkg/config"
+	"github.com/clau/email_verifier/pkg/utils"
+)
+
+// Default pattern learning settings used when the config leaves them empty
+const (
+	defaultPatternMinSamples = 3
+	defaultPatternMinShare   = 0.6
+)
+
+// DomainPattern is the naming convention learned for a domain's addresses
+type DomainPattern struct {
+	Domain  string
+	Pattern string // the most common name pattern, or "" if none is followed
+	Matches int    // samples following the pattern
+	Samples int    // addresses learned from
+	Learned bool   // the pattern is followed widely enough to be the domain's convention
+}
+
+// PatternLearner learns the name pattern each domain builds its addresses
+// with from verified addresses whose owner's name is known. Addresses are
+// learned from if their mailbox was confirmed or, at catch-all domains where
+// no mailbox can be confirmed, if it was not rejected. Each address counts
+// once, however often it appears.
+type PatternLearner struct {
+	minSamples int
+	minShare   float64
+
+	mu      sync.Mutex
+	domains map[string]map[string]string // domain -> normalized email -> pattern, "" for none
+}
+
+// NewPatternLearner creates a learner with the thresholds in the config
+func NewPatternLearner(cfg config.PatternConfig) *PatternLearner {
+	l := &PatternLearner{
+		minSamples: cfg.MinSamples,
+		minShare:   cfg.MinShare,
+		domains:    make(map[string]map[string]string),
+	}
+	if l.minSamples <= 0 {
+		l.minSamples = defaultPatternMinSamples
+	}
+	if l.minShare <= 0 {
+		l.minShare = defaultPatternMinShare
+	}
+	return l
+}
+
+// sample returns the domain and name pattern a result teaches, and whether
+// it is one to learn from
+func sample(result Result, lead Lead) (domain, pattern string, ok bool) {
+	if result.Checks == nil || result.ErrorCategory != "" || result.VerificationStatus == "invalid" {
+		return "", "", false
+	}
+	if result.Checks.Reachable != "yes" && !result.Checks.CatchAll {
+		return "", "", false
+	}
+	if nameToken(lead.FirstName) == "" && nameToken(lead.LastName) == "" {
+		return "", "", false
+	}
+	return domainOf(result.Email), matchNamePattern(result.Email, lead.FirstName, lead.LastName), true
+}
+
+// Observe learns from the result of an address and the lead it belongs to
+func (l *PatternLearner) Observe(result Result, lead Lead) {
+	domain, pattern, ok := sample(result, lead)
+	if !ok {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.domains[domain] == nil {
+		l.domains[domain] = make(map[string]string)
+	}
+	l.domains[domain][NormalizeEmail(result.Email)] = pattern
+}
+
+// learn returns the most common pattern among a domain's samples, leaving
+// out the address except, so an address cannot vouch for itself
+func (l *PatternLearner) learn(domain, except string) DomainPattern {
+	learned := DomainPattern{Domain: domain}
+	counts := make(map[string]int)
+	for email, pattern := range l.domains[domain] {
+		if email == except {
+			continue
+		}
+		learned.Samples++
+		if pattern != "" {
+			counts[pattern]++
+		}
+	}
+	// Ties go to the more common pattern
+	for _, pattern := range namePatterns {
+		if counts[pattern.name] > learned.Matches {
+			learned.Pattern, learned.Matches = pattern.name, counts[pattern.name]
+		}
+	}
+	learned.Learned = learned.Pattern != "" && learned.Samples >= l.minSamples &&
+		float64(learned.Matches) >= l.minShare*float64(learned.Samples)
+	return learned
+}
+
+// Patterns returns what was learned for each domain, sorted by domain
+func (l *PatternLearner) Patterns() []DomainPattern {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	patterns := make([]DomainPattern, 0, len(l.domains))
+	for domain := range l.domains {
+		patterns = append(patterns, l.learn(domain, ""))
+	}
+	slices.SortFunc(patterns, func(a, b DomainPattern) int {
+		return strings.Compare(a.Domain, b.Domain)
+	})
+	return patterns
+}
+
+// CheckLearnedPattern raises the score of an address at a catch-all domain
+// that follows the pattern the learner found for the domain's other
+// addresses, since the server cannot confirm it. Other results are returned
+// unchanged.
+func (v *Verifier) CheckLearnedPattern(result Result, lead Lead, learner *PatternLearner) Result {
+	if result.Checks == nil || !result.Checks.CatchAll || result.ErrorCategory != "" || slices.ContainsFunc(result.Reasons, func(r Reason) bool {
+		return slices.Contains(forcingReasons, r.Code)
+	}) {
+		return result
+	}
+	domain, pattern, ok := sample(result, lead)
+	if !ok || pattern == "" {
+		return result
+	}
+
+	learner.mu.Lock()
+	learned := learner.learn(domain, NormalizeEmail(result.Email))
+	learner.mu.Unlock()
+	if !learned.Learned || learned.Pattern != pattern {
+		return result
+	}
+
+	// The result may be shared with other records, so extend a copy of it
+	result.Reasons = slices.Clip(result.Reasons)
+	score := result.ConfidenceScore + result.addReason(ReasonLearnedPatternMatch, v.config.ScoringWeights.LearnedPatternMatch)
+	result.ConfidenceScore = utils.Max(0, utils.Min(score, 100))
+	result.VerificationStatus = v.status(result.ConfidenceScore)
+	return result
+}
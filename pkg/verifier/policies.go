@@ -0,0 +1,255 @@
+package verifier
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// MailPolicies holds the mail policies a domain publishes in DNS. A
+// policy is nil when the domain publishes none. Unresolved lists the
+// policies whose lookup failed, which are unknown rather than missing.
+type MailPolicies struct {
+	SPF        *SPFPolicy    `json:"spf"`
+	DMARC      *DMARCPolicy  `json:"dmarc"`
+	MTASTS     *MTASTSPolicy `json:"mta_sts"`
+	TLSRPT     *TLSRPTPolicy `json:"tls_rpt"`
+	Unresolved []string      `json:"unresolved,omitempty"` // spf, dmarc, mta_sts or tls_rpt
+}
+
+// SPFPolicy is a domain's SPF record (RFC 7208)
+type SPFPolicy struct {
+	Record string `json:"record"`
+	All    string `json:"all,omitempty"` // the catch-all mechanism: -all, ~all, ?all or +all
+}
+
+// DMARCPolicy is a domain's DMARC record (RFC 7489), or that of its
+// organizational domain when it has none of its own
+type DMARCPolicy struct {
+	Record          string   `json:"record"`
+	Domain          string   `json:"domain,omitempty"`           // the organizational domain the record is from, if not the domain's own
+	Policy          string   `json:"policy"`                     // none, quarantine or reject
+	SubdomainPolicy string   `json:"subdomain_policy,omitempty"` // policy for subdomains, if different
+	Percent         int      `json:"percent"`                    // share of mail the policy applies to
+	RUA             []string `json:"rua,omitempty"`              // where aggregate reports go
+}
+
+// MTASTSPolicy is a domain's MTA-STS record (RFC 8461). The policy file it
+// announces is not fetched.
+type MTASTSPolicy struct {
+	Record string `json:"record"`
+	ID     string `json:"id"`
+}
+
+// TLSRPTPolicy is a domain's SMTP TLS reporting record (RFC 8460)
+type TLSRPTPolicy struct {
+	Record string   `json:"record"`
+	RUA    []string `json:"rua,omitempty"` // where TLS reports go
+}
+
+// lookupPolicies looks up a domain's mail policies, concurrently. A domain
+// without a DMARC record of its own falls under that of its organizational
+// domain (RFC 7489 section 6.6.3).
+func lookupPolicies(ctx context.Context, resolver Resolver, domain string) *MailPolicies {
+	policies := &MailPolicies{}
+	dmarcHosts := []string{"_dmarc." + domain}
+	if org, err := publicsuffix.EffectiveTLDPlusOne(domain); err == nil && org != domain {
+		dmarcHosts = append(dmarcHosts, "_dmarc."+org)
+	}
+	lookups := []struct {
+		name  string
+		hosts []string // queried in turn until one has the record
+		parse func(record, host string) bool
+	}{
+		{"spf", []string{domain}, func(record, _ string) bool {
+			policies.SPF = parseSPF(record)
+			return policies.SPF != nil
+		}},
+		{"dmarc", dmarcHosts, func(record, host string) bool {
+			policies.DMARC = parseDMARC(record)
+			if policies.DMARC != nil && host != dmarcHosts[0] {
+				policies.DMARC.inherit(strings.TrimPrefix(host, "_dmarc."))
+			}
+			return policies.DMARC != nil
+		}},
+		{"mta_sts", []string{"_mta-sts." + domain}, func(record, _ string) bool {
+			policies.MTASTS = parseMTASTS(record)
+			return policies.MTASTS != nil
+		}},
+		{"tls_rpt", []string{"_smtp._tls." + domain}, func(record, _ string) bool {
+			policies.TLSRPT = parseTLSRPT(record)
+			return policies.TLSRPT != nil
+		}},
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, lookup := range lookups {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, host := range lookup.hosts {
+				records, err := resolver.LookupTXT(ctx, host)
+				mu.Lock()
+				failed := err != nil && !isNotFound(err)
+				if failed {
+					policies.Unresolved = append(policies.Unresolved, lookup.name)
+				}
+				found := !failed && slices.ContainsFunc(records, func(record string) bool {
+					return lookup.parse(record, host)
+				})
+				mu.Unlock()
+				if failed || found {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	slices.Sort(policies.Unresolved)
+	return policies
+}
+
+// isNotFound reports whether a DNS error means the name has no records,
+// rather than that the lookup failed
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// resolved reports whether the lookup of the named policy succeeded, so a
+// nil policy means the domain publishes none
+func (p *MailPolicies) resolved(name string) bool {
+	return !slices.Contains(p.Unresolved, name)
+}
+
+// Missing returns the names of the policies the domain was found not to
+// publish: spf, dmarc, mta_sts or tls_rpt
+func (p *MailPolicies) Missing() []string {
+	var missing []string
+	for _, policy := range []struct {
+		name      string
+		published bool
+	}{
+		{"spf", p.SPF != nil},
+		{"dmarc", p.DMARC != nil},
+		{"mta_sts", p.MTASTS != nil},
+		{"tls_rpt", p.TLSRPT != nil},
+	} {
+		if !policy.published && p.resolved(policy.name) {
+			missing = append(missing, policy.name)
+		}
+	}
+	return missing
+}
+
+// hasVersion reports whether a record starts with the version tag, such as
+// "v=spf1", followed by the end of the record or a separator
+func hasVersion(record, version string, separators string) bool {
+	if len(record) < len(version) || !strings.EqualFold(record[:len(version)], version) {
+		return false
+	}
+	rest := record[len(version):]
+	return rest == "" || strings.ContainsAny(rest[:1], separators)
+}
+
+// parseTags splits a tag-value list such as "v=DMARC1; p=reject" into its
+// tags, keyed in lowercase
+func parseTags(record string) map[string]string {
+	tags := make(map[string]string)
+	for _, part := range strings.Split(record, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if ok {
+			tags[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	return tags
+}
+
+// splitURIs splits a comma-separated list of report URIs
+func splitURIs(list string) []string {
+	var uris []string
+	for _, uri := range strings.Split(list, ",") {
+		if uri = strings.TrimSpace(uri); uri != "" {
+			uris = append(uris, uri)
+		}
+	}
+	return uris
+}
+
+// parseSPF parses an SPF record, returning nil if the record is not one
+func parseSPF(record string) *SPFPolicy {
+	record = strings.TrimSpace(record)
+	if !hasVersion(record, "v=spf1", " ") {
+		return nil
+	}
+	spf := &SPFPolicy{Record: record}
+	for _, term := range strings.Fields(record)[1:] {
+		switch strings.ToLower(term) {
+		case "all", "+all":
+			spf.All = "+all"
+		case "-all", "~all", "?all":
+			spf.All = strings.ToLower(term)
+		}
+	}
+	return spf
+}
+
+// parseDMARC parses a DMARC record, returning nil if the record is not one
+// or has no valid policy
+func parseDMARC(record string) *DMARCPolicy {
+	record = strings.TrimSpace(record)
+	if !hasVersion(record, "v=DMARC1", " ;") {
+		return nil
+	}
+	tags := parseTags(record)
+	dmarc := &DMARCPolicy{Record: record, Policy: strings.ToLower(tags["p"]), Percent: 100}
+	switch dmarc.Policy {
+	case "none", "quarantine", "reject":
+	default:
+		return nil
+	}
+	if sp := strings.ToLower(tags["sp"]); sp != dmarc.Policy {
+		dmarc.SubdomainPolicy = sp
+	}
+	if pct, err := strconv.Atoi(tags["pct"]); err == nil && pct >= 0 && pct <= 100 {
+		dmarc.Percent = pct
+	}
+	dmarc.RUA = splitURIs(tags["rua"])
+	return dmarc
+}
+
+// inherit makes an organizational domain's DMARC policy that of its
+// subdomain, to which the subdomain policy applies
+func (d *DMARCPolicy) inherit(org string) {
+	d.Domain = org
+	if d.SubdomainPolicy != "" {
+		d.Policy, d.SubdomainPolicy = d.SubdomainPolicy, ""
+	}
+}
+
+// parseMTASTS parses an MTA-STS record, returning nil if the record is not
+// one
+func parseMTASTS(record string) *MTASTSPolicy {
+	record = strings.TrimSpace(record)
+	if !hasVersion(record, "v=STSv1", " ;") {
+		return nil
+	}
+	return &MTASTSPolicy{Record: record, ID: parseTags(record)["id"]}
+}
+
+// parseTLSRPT parses an SMTP TLS reporting record, returning nil if the
+// record is not one
+func parseTLSRPT(record string) *TLSRPTPolicy {
+	record = strings.TrimSpace(record)
+	if !hasVersion(record, "v=TLSRPTv1", " ;") {
+		return nil
+	}
+	return &TLSRPTPolicy{Record: record, RUA: splitURIs(parseTags(record)["rua"])}
+}
@@ -0,0 +1,272 @@
+package verifier
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"slices"
+	"testing"
+
+	emailverifier "github.com/AfterShip/email-verifier"
+	"github.com/clau/email_verifier/pkg/config"
+)
+
+func TestParseSPF(t *testing.T) {
+	tests := []struct {
+		record string
+		want   *SPFPolicy
+	}{
+		{"v=spf1 include:_spf.google.com ~all", &SPFPolicy{Record: "v=spf1 include:_spf.google.com ~all", All: "~all"}},
+		{"  V=SPF1 mx -ALL ", &SPFPolicy{Record: "V=SPF1 mx -ALL", All: "-all"}},
+		{"v=spf1 all", &SPFPolicy{Record: "v=spf1 all", All: "+all"}},
+		{"v=spf1", &SPFPolicy{Record: "v=spf1"}},
+		{"v=spf10 -all", nil},
+		{"google-site-verification=abc123", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := parseSPF(tt.record); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSPF(%q) = %+v, want %+v", tt.record, got, tt.want)
+		}
+	}
+}
+
+func TestParseDMARC(t *testing.T) {
+	tests := []struct {
+		name   string
+		record string
+		want   *DMARCPolicy
+	}{
+		{
+			name:   "full record",
+			record: "v=DMARC1; p=Reject; sp=none; pct=50; rua=mailto:a@example.com, mailto:b@example.com",
+			want: &DMARCPolicy{
+				Record:          "v=DMARC1; p=Reject; sp=none; pct=50; rua=mailto:a@example.com, mailto:b@example.com",
+				Policy:          "reject",
+				SubdomainPolicy: "none",
+				Percent:         50,
+				RUA:             []string{"mailto:a@example.com", "mailto:b@example.com"},
+			},
+		},
+		{
+			name:   "policy only",
+			record: "v=DMARC1;p=none",
+			want:   &DMARCPolicy{Record: "v=DMARC1;p=none", Policy: "none", Percent: 100},
+		},
+		{
+			name:   "subdomain policy same as policy",
+			record: "v=DMARC1; p=quarantine; sp=quarantine",
+			want:   &DMARCPolicy{Record: "v=DMARC1; p=quarantine; sp=quarantine", Policy: "quarantine", Percent: 100},
+		},
+		{
+			name:   "pct above range",
+			record: "v=DMARC1; p=reject; pct=150",
+			want:   &DMARCPolicy{Record: "v=DMARC1; p=reject; pct=150", Policy: "reject", Percent: 100},
+		},
+		{
+			name:   "pct below range",
+			record: "v=DMARC1; p=reject; pct=-5",
+			want:   &DMARCPolicy{Record: "v=DMARC1; p=reject; pct=-5", Policy: "reject", Percent: 100},
+		},
+		{
+			name:   "pct not a number",
+			record: "v=DMARC1; p=reject; pct=half",
+			want:   &DMARCPolicy{Record: "v=DMARC1; p=reject; pct=half", Policy: "reject", Percent: 100},
+		},
+		{name: "malformed policy", record: "v=DMARC1; p=rejected", want: nil},
+		{name: "empty policy", record: "v=DMARC1; p=", want: nil},
+		{name: "missing policy", record: "v=DMARC1; rua=mailto:a@example.com", want: nil},
+		{name: "wrong version", record: "v=DMARC2; p=reject", want: nil},
+		{name: "not DMARC", record: "v=spf1 -all", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDMARC(tt.record); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDMARC(%q) = %+v, want %+v", tt.record, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMTASTS(t *testing.T) {
+	tests := []struct {
+		record string
+		want   *MTASTSPolicy
+	}{
+		{"v=STSv1; id=20240101T000000", &MTASTSPolicy{Record: "v=STSv1; id=20240101T000000", ID: "20240101T000000"}},
+		{"v=STSv1;", &MTASTSPolicy{Record: "v=STSv1;"}},
+		{"v=STSv10; id=1", nil},
+		{"v=spf1 -all", nil},
+	}
+	for _, tt := range tests {
+		if got := parseMTASTS(tt.record); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseMTASTS(%q) = %+v, want %+v", tt.record, got, tt.want)
+		}
+	}
+}
+
+func TestParseTLSRPT(t *testing.T) {
+	tests := []struct {
+		record string
+		want   *TLSRPTPolicy
+	}{
+		{
+			"v=TLSRPTv1; rua=mailto:tls@example.com,https://report.example.com/tls",
+			&TLSRPTPolicy{
+				Record: "v=TLSRPTv1; rua=mailto:tls@example.com,https://report.example.com/tls",
+				RUA:    []string{"mailto:tls@example.com", "https://report.example.com/tls"},
+			},
+		},
+		{"v=TLSRPTv1", &TLSRPTPolicy{Record: "v=TLSRPTv1"}},
+		{"v=TLSRPTv2; rua=mailto:tls@example.com", nil},
+	}
+	for _, tt := range tests {
+		if got := parseTLSRPT(tt.record); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseTLSRPT(%q) = %+v, want %+v", tt.record, got, tt.want)
+		}
+	}
+}
+
+// servfail is the error a resolver gives when the nameserver fails
+var servfail = &net.DNSError{Err: "server misbehaving", Name: "example.com", IsTemporary: true}
+
+func TestLookupPolicies(t *testing.T) {
+	resolver := &FakeResolver{
+		TXT: map[string][]string{
+			"example.com":            {"google-site-verification=abc123", "v=spf1 mx -all"},
+			"_smtp._tls.example.com": {"v=TLSRPTv1; rua=mailto:tls@example.com"},
+			"nodmarc.example":        {"v=spf1 -all"},
+			"_dmarc.nodmarc.example": {"not a dmarc record"},
+			"_dmarc.example.net":     {"v=DMARC1; p=reject"},
+		},
+		Errors: map[string]error{"_dmarc.example.com": servfail},
+	}
+
+	policies := lookupPolicies(context.Background(), resolver, "example.com")
+	if policies.SPF == nil || policies.SPF.Record != "v=spf1 mx -all" {
+		t.Errorf("SPF = %+v, want the v=spf1 record", policies.SPF)
+	}
+	if policies.DMARC != nil {
+		t.Errorf("DMARC = %+v, want nil", policies.DMARC)
+	}
+	if policies.TLSRPT == nil || !slices.Equal(policies.TLSRPT.RUA, []string{"mailto:tls@example.com"}) {
+		t.Errorf("TLSRPT = %+v, want rua mailto:tls@example.com", policies.TLSRPT)
+	}
+	if want := []string{"dmarc"}; !slices.Equal(policies.Unresolved, want) {
+		t.Errorf("Unresolved = %v, want %v", policies.Unresolved, want)
+	}
+	if want := []string{"mta_sts"}; !slices.Equal(policies.Missing(), want) {
+		t.Errorf("Missing() = %v, want %v", policies.Missing(), want)
+	}
+
+	// A TXT record that is not a policy does not count as one
+	policies = lookupPolicies(context.Background(), resolver, "nodmarc.example")
+	if want := []string{"dmarc", "mta_sts", "tls_rpt"}; !slices.Equal(policies.Missing(), want) {
+		t.Errorf("Missing() = %v, want %v", policies.Missing(), want)
+	}
+
+	// A subdomain without a DMARC record has its organizational domain's
+	policies = lookupPolicies(context.Background(), resolver, "mail.example.net")
+	if policies.DMARC == nil || policies.DMARC.Domain != "example.net" || policies.DMARC.Policy != "reject" {
+		t.Errorf("DMARC = %+v, want example.net's reject policy", policies.DMARC)
+	}
+	if want := []string{"spf", "mta_sts", "tls_rpt"}; !slices.Equal(policies.Missing(), want) {
+		t.Errorf("Missing() = %v, want %v", policies.Missing(), want)
+	}
+}
+
+func TestLookupPoliciesOrganizationalDMARC(t *testing.T) {
+	resolver := &FakeResolver{
+		TXT: map[string][]string{
+			"_dmarc.example.org":     {"v=DMARC1; p=reject; sp=quarantine; rua=mailto:dmarc@example.org"},
+			"_dmarc.own.example.org": {"v=DMARC1; p=none"},
+		},
+		Errors: map[string]error{"_dmarc.example.com": servfail},
+	}
+	tests := []struct {
+		domain     string
+		want       *DMARCPolicy
+		unresolved []string
+	}{
+		{
+			domain: "mail.example.org",
+			want: &DMARCPolicy{
+				Record:  "v=DMARC1; p=reject; sp=quarantine; rua=mailto:dmarc@example.org",
+				Domain:  "example.org",
+				Policy:  "quarantine",
+				Percent: 100,
+				RUA:     []string{"mailto:dmarc@example.org"},
+			},
+		},
+		{
+			domain: "example.org",
+			want: &DMARCPolicy{
+				Record:          "v=DMARC1; p=reject; sp=quarantine; rua=mailto:dmarc@example.org",
+				Policy:          "reject",
+				SubdomainPolicy: "quarantine",
+				Percent:         100,
+				RUA:             []string{"mailto:dmarc@example.org"},
+			},
+		},
+		{
+			domain: "own.example.org",
+			want:   &DMARCPolicy{Record: "v=DMARC1; p=none", Policy: "none", Percent: 100},
+		},
+		{domain: "a.b.example.com", unresolved: []string{"dmarc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			policies := lookupPolicies(context.Background(), resolver, tt.domain)
+			if !reflect.DeepEqual(policies.DMARC, tt.want) {
+				t.Errorf("DMARC = %+v, want %+v", policies.DMARC, tt.want)
+			}
+			if !slices.Equal(policies.Unresolved, tt.unresolved) {
+				t.Errorf("Unresolved = %v, want %v", policies.Unresolved, tt.unresolved)
+			}
+		})
+	}
+}
+
+func TestDetermineStatusPolicyReasons(t *testing.T) {
+	tests := []struct {
+		name    string
+		errs    map[string]error
+		reasons []ReasonCode
+	}{
+		{
+			name:    "policies not published",
+			reasons: []ReasonCode{ReasonNoSPF, ReasonNoDMARC},
+		},
+		{
+			name: "policy lookups failed",
+			errs: map[string]error{"example.com": servfail, "_dmarc.example.com": servfail},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(&config.Config{ValidThreshold: 80, RiskyThreshold: 60, ScoringWeights: config.ScoringWeights{NoSPF: -5, NoDMARC: -5}})
+			resolver := &FakeResolver{Errors: tt.errs}
+			lookup := &Lookup{
+				Result: &emailverifier.Result{
+					Syntax:       emailverifier.Syntax{Username: "jane", Domain: "example.com", Valid: true},
+					HasMxRecords: true,
+					Reachable:    "yes",
+				},
+				Policies: lookupPolicies(context.Background(), resolver, "example.com"),
+			}
+
+			result := v.DetermineStatus(lookup, "jane@example.com")
+			var got []ReasonCode
+			for _, reason := range result.Reasons {
+				if reason.Code == ReasonNoSPF || reason.Code == ReasonNoDMARC {
+					got = append(got, reason.Code)
+				}
+			}
+			if !slices.Equal(got, tt.reasons) {
+				t.Errorf("policy reasons = %v, want %v", got, tt.reasons)
+			}
+		})
+	}
+}
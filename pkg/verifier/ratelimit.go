@@ -0,0 +1,161 @@
+package verifier
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clau/email_verifier/pkg/config"
+)
+
+// Default rates (requests per second) used when the config leaves them empty
+const (
+	defaultGlobalRate = 10
+	defaultDomainRate = 2
+	defaultMXRate     = 5
+	defaultBurst      = 1
+
+	// A server answering with temporary failures is slowed down to no less
+	// than this fraction of its configured rate
+	minRateFactor = 1.0 / 16
+)
+
+// tokenBucket allows rate events per second with bursts of up to burst events
+type tokenBucket struct {
+	maxRate float64 // configured rate
+	rate    float64 // current rate, lowered after temporary failures
+	burst   float64
+	tokens  float64
+	last    time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		maxRate: rate,
+		rate:    rate,
+		burst:   float64(burst),
+		tokens:  float64(burst),
+		last:    time.Now(),
+	}
+}
+
+// reserve takes a token and returns how long to wait before using it.
+// Tokens may go negative so that concurrent callers queue up behind each other.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// rateLimiter limits SMTP traffic globally, per recipient domain and per MX host
+type rateLimiter struct {
+	mu         sync.Mutex
+	global     *tokenBucket
+	domains    map[string]*tokenBucket
+	mxHosts    map[string]*tokenBucket
+	domainRate float64
+	mxRate     float64
+	burst      int
+}
+
+func newRateLimiter(limits config.RateLimits) *rateLimiter {
+	rl := &rateLimiter{
+		domains:    make(map[string]*tokenBucket),
+		mxHosts:    make(map[string]*tokenBucket),
+		domainRate: limits.PerDomain,
+		mxRate:     limits.PerMX,
+		burst:      limits.Burst,
+	}
+	globalRate := limits.Global
+	if globalRate <= 0 {
+		globalRate = defaultGlobalRate
+	}
+	if rl.domainRate <= 0 {
+		rl.domainRate = defaultDomainRate
+	}
+	if rl.mxRate <= 0 {
+		rl.mxRate = defaultMXRate
+	}
+	if rl.burst <= 0 {
+		rl.burst = defaultBurst
+	}
+	rl.global = newTokenBucket(globalRate, rl.burst)
+	return rl
+}
+
+// wait blocks until a request to the domain through the MX host is allowed,
+// or until ctx is done
+func (rl *rateLimiter) wait(ctx context.Context, domain, mxHost string) error {
+	if rl == nil {
+		return nil
+	}
+
+	rl.mu.Lock()
+	now := time.Now()
+	delay := rl.global.reserve(now)
+	if d := rl.bucket(rl.domains, domain, rl.domainRate).reserve(now); d > delay {
+		delay = d
+	}
+	if d := rl.bucket(rl.mxHosts, mxHost, rl.mxRate).reserve(now); d > delay {
+		delay = d
+	}
+	rl.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// slowDown halves the rate for an MX host that answered with a temporary failure
+func (rl *rateLimiter) slowDown(mxHost string) {
+	if rl == nil {
+		return
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	b := rl.bucket(rl.mxHosts, mxHost, rl.mxRate)
+	b.rate = math.Max(b.rate/2, b.maxRate*minRateFactor)
+}
+
+// speedUp moves the rate for an MX host back towards its configured rate
+func (rl *rateLimiter) speedUp(mxHost string) {
+	if rl == nil {
+		return
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	b := rl.bucket(rl.mxHosts, mxHost, rl.mxRate)
+	b.rate = math.Min(b.rate*1.25, b.maxRate)
+}
+
+// bucket returns the bucket for key, creating it on first use
+func (rl *rateLimiter) bucket(buckets map[string]*tokenBucket, key string, rate float64) *tokenBucket {
+	key = strings.ToLower(key)
+	b, ok := buckets[key]
+	if !ok {
+		b = newTokenBucket(rate, rl.burst)
+		buckets[key] = b
+	}
+	return b
+}
+
+// isTemporaryReply reports whether an SMTP reply code asks the client to back off
+func isTemporaryReply(code int) bool {
+	return code >= 400 && code < 500
+}
@@ -0,0 +1,48 @@
+package verifier
+
+// ReasonCode is a stable, machine-readable code explaining a verification decision
+type ReasonCode string
+
+// Reason codes reported in Result.Reasons
+const (
+	ReasonSyntaxInvalid       ReasonCode = "SYNTAX_INVALID"
+	ReasonMailboxNotFound     ReasonCode = "MAILBOX_NOT_FOUND"
+	ReasonSMTPUTF8Unsupported ReasonCode = "SMTPUTF8_UNSUPPORTED"
+	ReasonDisposable          ReasonCode = "DISPOSABLE"
+	ReasonMxRecordsFound      ReasonCode = "MX_RECORDS_FOUND"
+	ReasonNoMxRecords         ReasonCode = "NO_MX_RECORDS"
+	ReasonImplicitMX          ReasonCode = "IMPLICIT_MX"
+	ReasonNullMX              ReasonCode = "NULL_MX"
+	ReasonMailboxExists       ReasonCode = "MAILBOX_EXISTS"
+	ReasonReachabilityUnknown ReasonCode = "REACHABILITY_UNKNOWN"
+	ReasonCatchAll            ReasonCode = "CATCH_ALL"
+	ReasonGreylisted          ReasonCode = "GREYLISTED"
+	ReasonRoleAccount         ReasonCode = "ROLE_ACCOUNT"
+	ReasonFreeProvider        ReasonCode = "FREE_PROVIDER"
+	ReasonSuggestionAvailable ReasonCode = "SUGGESTION_AVAILABLE"
+	ReasonNoSPF               ReasonCode = "NO_SPF"
+	ReasonNoDMARC             ReasonCode = "NO_DMARC"
+	ReasonVerificationFailed  ReasonCode = "VERIFICATION_FAILED"
+)
+
+// Reason codes of the checks against the lead record an address came from
+const (
+	ReasonPersonalEmailForBusinessLead ReasonCode = "PERSONAL_EMAIL_FOR_BUSINESS_LEAD"
+	ReasonDomainMismatch               ReasonCode = "DOMAIN_MISMATCH"
+	ReasonNameMatch                    ReasonCode = "NAME_MATCH"
+	ReasonNameMismatch                 ReasonCode = "NAME_MISMATCH"
+	ReasonLearnedPatternMatch          ReasonCode = "LEARNED_PATTERN_MATCH"
+)
+
+// Reason records why a status was assigned and how much it contributed to
+// the confidence score. Reasons that force a status carry no weight.
+type Reason struct {
+	Code   ReasonCode `json:"code"`
+	Weight int        `json:"weight"`
+}
+
+// addReason records a reason on the result and returns its weight
+func (r *Result) addReason(code ReasonCode, weight int) int {
+	r.Reasons = append(r.Reasons, Reason{Code: code, Weight: weight})
+	return weight
+}
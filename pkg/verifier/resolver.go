@@ -0,0 +1,312 @@
+package verifier
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/clau/email_verifier/pkg/config"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Default DNS settings used when the config leaves them empty
+const (
+	defaultDNSTimeout = 5 * time.Second
+	defaultDNSPort    = "53"
+	maxDoHResponse    = 64 * 1024 // largest DNS-over-HTTPS answer read, in bytes
+)
+
+// Resolver looks up the DNS records the verifier needs. It is satisfied by
+// *net.Resolver; NewResolver builds one from the dns settings in the config,
+// and tests can answer from a FakeResolver.
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// NewResolver creates the resolver the dns settings in the config select:
+// the system resolver, the given nameservers over UDP or TCP, or a
+// DNS-over-HTTPS server
+func NewResolver(cfg config.DNSConfig) Resolver {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultDNSTimeout
+	}
+
+	switch strings.ToLower(cfg.Type) {
+	case "udp", "tcp":
+		return newNameserverResolver(strings.ToLower(cfg.Type), cfg.Nameservers, timeout)
+	case "doh":
+		return &DoHResolver{URL: cfg.DoHURL, Client: &http.Client{Timeout: timeout}}
+	default:
+		return net.DefaultResolver
+	}
+}
+
+// nameserverResolver resolves names with the given nameservers instead of
+// those of the system, taking them in turn
+type nameserverResolver struct {
+	resolver  *net.Resolver
+	network   string // udp or tcp
+	addresses []string
+	dialer    *net.Dialer
+	next      atomic.Uint32
+}
+
+// lastNameserverKey is the context key under which a lookup records the
+// nameserver it last queried
+type lastNameserverKey struct{}
+
+// lastNameserver is the nameserver a lookup last queried
+type lastNameserver struct {
+	mu      sync.Mutex
+	address string
+}
+
+func newNameserverResolver(network string, nameservers []string, timeout time.Duration) *nameserverResolver {
+	r := &nameserverResolver{network: network, dialer: &net.Dialer{Timeout: timeout}}
+	for _, nameserver := range nameservers {
+		r.addresses = append(r.addresses, nameserverAddress(nameserver))
+	}
+	r.resolver = &net.Resolver{PreferGo: true, Dial: r.dial}
+	return r
+}
+
+// dial connects the Go resolver to a nameserver over the network it asks
+// for, or always over TCP for a tcp resolver. A query goes to the next
+// nameserver, so a failed one is retried on another, except that a query
+// whose UDP answer was truncated is retried over TCP on the same nameserver.
+func (r *nameserverResolver) dial(ctx context.Context, network, _ string) (net.Conn, error) {
+	// The Go resolver only asks for TCP after a truncated UDP answer
+	truncated := network == "tcp" && r.network == "udp"
+	if r.network == "tcp" {
+		network = "tcp"
+	}
+	last, ok := ctx.Value(lastNameserverKey{}).(*lastNameserver)
+	if !ok {
+		last = &lastNameserver{}
+	}
+
+	last.mu.Lock()
+	address := last.address
+	if !truncated || address == "" {
+		address = r.addresses[int(r.next.Add(1)-1)%len(r.addresses)]
+		last.address = address
+	}
+	last.mu.Unlock()
+	return r.dialer.DialContext(ctx, network, address)
+}
+
+// lookupContext returns the context for a lookup, recording the nameservers
+// it queries
+func lookupContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, lastNameserverKey{}, &lastNameserver{})
+}
+
+// LookupMX returns the MX records of a name, sorted by preference
+func (r *nameserverResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return r.resolver.LookupMX(lookupContext(ctx), name)
+}
+
+// LookupTXT returns the TXT records of a name
+func (r *nameserverResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return r.resolver.LookupTXT(lookupContext(ctx), name)
+}
+
+// LookupHost returns the addresses of a host
+func (r *nameserverResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return r.resolver.LookupHost(lookupContext(ctx), host)
+}
+
+// nameserverAddress adds the DNS port to a nameserver given without one
+func nameserverAddress(nameserver string) string {
+	if _, _, err := net.SplitHostPort(nameserver); err == nil {
+		return nameserver
+	}
+	return net.JoinHostPort(strings.Trim(nameserver, "[]"), defaultDNSPort)
+}
+
+// DoHResolver resolves names over DNS-over-HTTPS (RFC 8484), posting
+// wire-format queries to a server such as https://cloudflare-dns.com/dns-query
+type DoHResolver struct {
+	URL    string
+	Client *http.Client
+}
+
+// LookupMX returns the MX records of a name, sorted by preference
+func (r *DoHResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	answers, err := r.query(ctx, name, dnsmessage.TypeMX)
+	if err != nil {
+		return nil, err
+	}
+	var records []*net.MX
+	for _, answer := range answers {
+		if mx, ok := answer.Body.(*dnsmessage.MXResource); ok {
+			records = append(records, &net.MX{Host: mx.MX.String(), Pref: mx.Pref})
+		}
+	}
+	slices.SortStableFunc(records, func(a, b *net.MX) int {
+		return int(a.Pref) - int(b.Pref)
+	})
+	return records, nil
+}
+
+// LookupTXT returns the TXT records of a name, each with its strings joined
+func (r *DoHResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	answers, err := r.query(ctx, name, dnsmessage.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	var records []string
+	for _, answer := range answers {
+		if txt, ok := answer.Body.(*dnsmessage.TXTResource); ok {
+			records = append(records, strings.Join(txt.TXT, ""))
+		}
+	}
+	return records, nil
+}
+
+// LookupHost returns the IPv4 and IPv6 addresses of a host. It fails only if
+// neither lookup finds any.
+func (r *DoHResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	var addrs []string
+	var lastErr error
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		answers, err := r.query(ctx, host, qtype)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, answer := range answers {
+			switch body := answer.Body.(type) {
+			case *dnsmessage.AResource:
+				addrs = append(addrs, net.IP(body.A[:]).String())
+			case *dnsmessage.AAAAResource:
+				addrs = append(addrs, net.IP(body.AAAA[:]).String())
+			}
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, lastErr
+	}
+	return addrs, nil
+}
+
+// query asks the server for the records of a type and returns the answers
+// of that type. A name without any is reported as not found, like the
+// system resolver does.
+func (r *DoHResolver) query(ctx context.Context, name string, qtype dnsmessage.Type) ([]dnsmessage.Resource, error) {
+	dnsError := func(err error, temporary bool) error {
+		// The client or its connection may time out with a net.Error that
+		// is not a context deadline
+		var netErr net.Error
+		timeout := errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+		return &net.DNSError{Err: err.Error(), UnwrapErr: err, Name: name, Server: r.URL,
+			IsTimeout: timeout, IsTemporary: temporary}
+	}
+
+	qname, err := dnsmessage.NewName(strings.TrimSuffix(name, ".") + ".")
+	if err != nil {
+		return nil, dnsError(err, false)
+	}
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, dnsError(err, false)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(packed))
+	if err != nil {
+		return nil, dnsError(err, false)
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, dnsError(err, true)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, dnsError(fmt.Errorf("server answered %s", resp.Status), true)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDoHResponse))
+	if err != nil {
+		return nil, dnsError(err, true)
+	}
+
+	var answer dnsmessage.Message
+	if err := answer.Unpack(body); err != nil {
+		return nil, dnsError(err, true)
+	}
+	switch answer.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, &net.DNSError{Err: "no such host", Name: name, Server: r.URL, IsNotFound: true}
+	default:
+		return nil, dnsError(fmt.Errorf("server answered %v", answer.RCode), answer.RCode == dnsmessage.RCodeServerFailure)
+	}
+
+	var answers []dnsmessage.Resource
+	for _, resource := range answer.Answers {
+		if resource.Header.Type == qtype {
+			answers = append(answers, resource)
+		}
+	}
+	if len(answers) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: name, Server: r.URL, IsNotFound: true}
+	}
+	return answers, nil
+}
+
+// FakeResolver is an in-memory Resolver for tests, answering from fixed
+// records by name. Names without records are reported as not found, unless
+// Errors holds the error to fail their lookups with.
+type FakeResolver struct {
+	MX     map[string][]*net.MX
+	TXT    map[string][]string
+	Hosts  map[string][]string
+	Errors map[string]error
+}
+
+// LookupMX returns the MX records of a name
+func (r *FakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return fakeLookup(ctx, r, r.MX, name)
+}
+
+// LookupTXT returns the TXT records of a name
+func (r *FakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return fakeLookup(ctx, r, r.TXT, name)
+}
+
+// LookupHost returns the addresses of a host
+func (r *FakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return fakeLookup(ctx, r, r.Hosts, host)
+}
+
+// fakeLookup answers a lookup of a FakeResolver from one of its record sets
+func fakeLookup[T any](ctx context.Context, r *FakeResolver, records map[string][]T, name string) ([]T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if err, ok := r.Errors[name]; ok {
+		return nil, err
+	}
+	if found, ok := records[name]; ok && len(found) > 0 {
+		return found, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
@@ -0,0 +1,356 @@
+package verifier
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/clau/email_verifier/pkg/config"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// spfRecord is the TXT record the fake nameservers answer with
+const spfRecord = "v=spf1 include:_spf.example.net -all"
+
+// answerTXT builds the answer to a DNS query with the TXT records, flagged
+// as truncated if truncated is set
+func answerTXT(t *testing.T, query []byte, truncated bool, records ...string) []byte {
+	t.Helper()
+	var msg dnsmessage.Message
+	if err := msg.Unpack(query); err != nil {
+		t.Errorf("unpacking query: %v", err)
+		return nil
+	}
+	answer := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: msg.ID, Response: true, Truncated: truncated, RecursionAvailable: true},
+		Questions: msg.Questions,
+	}
+	for _, record := range records {
+		answer.Answers = append(answer.Answers, dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{Name: msg.Questions[0].Name, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET, TTL: 60},
+			Body:   &dnsmessage.TXTResource{TXT: []string{record}},
+		})
+	}
+	packed, err := answer.Pack()
+	if err != nil {
+		t.Errorf("packing answer: %v", err)
+	}
+	return packed
+}
+
+// serveUDP answers DNS queries over UDP on a local port until the test ends
+func serveUDP(t *testing.T, answer func(query []byte) []byte) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteToUDP(answer(buf[:n]), addr)
+		}
+	}()
+	return conn
+}
+
+// serveTCP answers DNS queries over TCP on the given local address until
+// the test ends
+func serveTCP(t *testing.T, address string, answer func(query []byte) []byte) {
+	t.Helper()
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatalf("listen tcp: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for {
+					var length uint16
+					if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+						return
+					}
+					query := make([]byte, length)
+					if _, err := io.ReadFull(conn, query); err != nil {
+						return
+					}
+					reply := answer(query)
+					conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(reply))))
+					conn.Write(reply)
+				}
+			}()
+		}
+	}()
+}
+
+func TestNameserverResolverRetriesTruncatedOverTCP(t *testing.T) {
+	// The first nameserver truncates its UDP answers and gives the full one
+	// over TCP; the second answers with no records, and not over TCP at all
+	truncating := serveUDP(t, func(query []byte) []byte { return answerTXT(t, query, true) })
+	serveTCP(t, truncating.LocalAddr().String(), func(query []byte) []byte {
+		return answerTXT(t, query, false, spfRecord)
+	})
+	empty := serveUDP(t, func(query []byte) []byte { return answerTXT(t, query, false) })
+
+	resolver := newNameserverResolver("udp", []string{truncating.LocalAddr().String(), empty.LocalAddr().String()}, time.Second)
+	records, err := resolver.LookupTXT(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("LookupTXT() error = %v", err)
+	}
+	if !slices.Equal(records, []string{spfRecord}) {
+		t.Errorf("LookupTXT() = %q, want %q", records, spfRecord)
+	}
+}
+
+func TestNameserverResolverOverTCP(t *testing.T) {
+	// A tcp resolver never asks over UDP, so the truncated answer is not seen
+	udp := serveUDP(t, func(query []byte) []byte { return answerTXT(t, query, true) })
+	serveTCP(t, udp.LocalAddr().String(), func(query []byte) []byte {
+		return answerTXT(t, query, false, spfRecord)
+	})
+
+	resolver := newNameserverResolver("tcp", []string{udp.LocalAddr().String()}, time.Second)
+	records, err := resolver.LookupTXT(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("LookupTXT() error = %v", err)
+	}
+	if !slices.Equal(records, []string{spfRecord}) {
+		t.Errorf("LookupTXT() = %q, want %q", records, spfRecord)
+	}
+}
+
+// dohHandler answers DNS-over-HTTPS queries for names under example.com:
+// ok has TXT and MX records, missing does not exist, nodata has no records
+// and broken fails on the server
+func dohHandler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading query: %v", err)
+			return
+		}
+		var query dnsmessage.Message
+		if err := query.Unpack(body); err != nil {
+			t.Errorf("unpacking query: %v", err)
+			return
+		}
+		question := query.Questions[0]
+		answer := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: query.ID, Response: true, RecursionAvailable: true},
+			Questions: query.Questions,
+		}
+		header := dnsmessage.ResourceHeader{Name: question.Name, Type: question.Type, Class: dnsmessage.ClassINET, TTL: 60}
+		switch question.Name.String() {
+		case "ok.example.com.":
+			switch question.Type {
+			case dnsmessage.TypeTXT:
+				answer.Answers = append(answer.Answers, dnsmessage.Resource{
+					Header: header,
+					Body:   &dnsmessage.TXTResource{TXT: []string{"v=spf1 ", "-all"}},
+				})
+			case dnsmessage.TypeMX:
+				for _, mx := range []struct {
+					host string
+					pref uint16
+				}{{"mx2.example.com.", 20}, {"mx1.example.com.", 10}} {
+					answer.Answers = append(answer.Answers, dnsmessage.Resource{
+						Header: header,
+						Body:   &dnsmessage.MXResource{Pref: mx.pref, MX: dnsmessage.MustNewName(mx.host)},
+					})
+				}
+			}
+		case "missing.example.com.":
+			answer.RCode = dnsmessage.RCodeNameError
+		case "broken.example.com.":
+			answer.RCode = dnsmessage.RCodeServerFailure
+		}
+		packed, err := answer.Pack()
+		if err != nil {
+			t.Errorf("packing answer: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(packed)
+	}
+}
+
+func TestDoHResolver(t *testing.T) {
+	server := httptest.NewServer(dohHandler(t))
+	defer server.Close()
+	resolver := &DoHResolver{URL: server.URL, Client: server.Client()}
+	ctx := context.Background()
+
+	records, err := resolver.LookupTXT(ctx, "ok.example.com")
+	if err != nil || !slices.Equal(records, []string{"v=spf1 -all"}) {
+		t.Errorf("LookupTXT(ok) = %q, %v; want [v=spf1 -all]", records, err)
+	}
+	mx, err := resolver.LookupMX(ctx, "ok.example.com")
+	if err != nil || len(mx) != 2 || mx[0].Host != "mx1.example.com." || mx[1].Host != "mx2.example.com." {
+		t.Errorf("LookupMX(ok) = %v, %v; want mx1 then mx2", mx, err)
+	}
+
+	tests := []struct {
+		name      string
+		notFound  bool
+		temporary bool
+	}{
+		{name: "missing.example.com", notFound: true},
+		{name: "nodata.example.com", notFound: true},
+		{name: "broken.example.com", temporary: true},
+	}
+	for _, tt := range tests {
+		_, err := resolver.LookupTXT(ctx, tt.name)
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) {
+			t.Errorf("LookupTXT(%s) error = %v, want a *net.DNSError", tt.name, err)
+			continue
+		}
+		if dnsErr.IsNotFound != tt.notFound || dnsErr.IsTemporary != tt.temporary {
+			t.Errorf("LookupTXT(%s) error = %v, not found %t and temporary %t; want %t and %t",
+				tt.name, err, dnsErr.IsNotFound, dnsErr.IsTemporary, tt.notFound, tt.temporary)
+		}
+	}
+}
+
+func TestDoHResolverHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	resolver := &DoHResolver{URL: server.URL, Client: server.Client()}
+
+	_, err := resolver.LookupTXT(context.Background(), "ok.example.com")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || dnsErr.IsNotFound || !dnsErr.IsTemporary {
+		t.Errorf("LookupTXT() error = %v, want a temporary *net.DNSError", err)
+	}
+}
+
+func TestDoHResolverTimeout(t *testing.T) {
+	// The server does not answer until the test ends, long after the client
+	// has given up, either before sending the headers or while sending the body
+	handler := func(headers bool, release chan struct{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if headers {
+				w.Header().Set("Content-Type", "application/dns-message")
+				w.WriteHeader(http.StatusOK)
+				w.(http.Flusher).Flush()
+			}
+			<-release
+		}
+	}
+
+	tests := []struct {
+		name    string
+		headers bool
+		timeout func(*http.Client)
+	}{
+		{"client timeout awaiting headers", false, func(c *http.Client) { c.Timeout = 50 * time.Millisecond }},
+		{"client timeout reading body", true, func(c *http.Client) { c.Timeout = 50 * time.Millisecond }},
+		{"connection deadline awaiting headers", false, func(c *http.Client) {
+			c.Transport.(*http.Transport).DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+				conn, err := (&net.Dialer{}).DialContext(ctx, network, address)
+				if err == nil {
+					conn.SetDeadline(time.Now().Add(50 * time.Millisecond))
+				}
+				return conn, err
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			server := httptest.NewServer(handler(tt.headers, release))
+			defer server.Close()
+			defer close(release)
+			client := server.Client()
+			tt.timeout(client)
+			resolver := &DoHResolver{URL: server.URL, Client: client}
+
+			_, err := resolver.LookupTXT(context.Background(), "ok.example.com")
+			var dnsErr *net.DNSError
+			if !errors.As(err, &dnsErr) || !dnsErr.IsTimeout || !dnsErr.IsTemporary {
+				t.Errorf("LookupTXT() error = %v, want a temporary *net.DNSError that timed out", err)
+			}
+		})
+	}
+}
+
+func TestSetResolverMailRoutes(t *testing.T) {
+	resolver := &FakeResolver{
+		MX: map[string][]*net.MX{
+			"example.com":  {{Host: "mx.example.com.", Pref: 10}},
+			"null.example": {{Host: ".", Pref: 0}},
+		},
+		Hosts: map[string][]string{
+			"mx.example.com":   {"127.0.0.1"},
+			"implicit.example": {"127.0.0.1"},
+		},
+		Errors: map[string]error{"broken.example": servfail},
+	}
+	tests := []struct {
+		email    string
+		route    string
+		mxHosts  []string
+		status   string
+		reason   ReasonCode
+		category ErrorCategory
+	}{
+		{email: "jane@example.com", route: "mx", mxHosts: []string{"mx.example.com"}, status: "valid", reason: ReasonMxRecordsFound},
+		{email: "jane@implicit.example", route: "implicit_mx", mxHosts: []string{"implicit.example"}, status: "valid", reason: ReasonImplicitMX},
+		{email: "jane@null.example", route: "null_mx", status: "invalid", reason: ReasonNullMX},
+		{email: "jane@missing.example", route: "none", status: "invalid", reason: ReasonNoMxRecords},
+		{email: "jane@broken.example", category: CategoryDNS},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			server := newFakeSMTPServer(t, replyFor(tt.email, "250 OK", "550 No such user"))
+			v := newTestVerifier(server, resolver)
+			v.config.ValidThreshold, v.config.RiskyThreshold = 80, 60
+			v.config.ScoringWeights = config.ScoringWeights{HasMxRecords: 20, ImplicitMX: 15, ReachableYes: 40}
+
+			lookup, err := v.VerifyContext(context.Background(), tt.email)
+			if got := ErrorCategoryOf(err); got != tt.category {
+				t.Fatalf("VerifyContext() error = %v, want category %q", err, tt.category)
+			}
+			if err != nil {
+				return
+			}
+			if lookup.MailRoute != tt.route || !slices.Equal(lookup.MXHosts, tt.mxHosts) {
+				t.Errorf("mail route = %q via %v, want %q via %v", lookup.MailRoute, lookup.MXHosts, tt.route, tt.mxHosts)
+			}
+
+			result := v.DetermineStatus(lookup, tt.email)
+			if result.VerificationStatus != tt.status {
+				t.Errorf("status = %q, want %q", result.VerificationStatus, tt.status)
+			}
+			if !slices.ContainsFunc(result.Reasons, func(r Reason) bool { return r.Code == tt.reason }) {
+				t.Errorf("reasons = %v, want %s", result.Reasons, tt.reason)
+			}
+
+			// A domain that accepts no mail is not probed
+			if sessions, _ := server.stats(); (sessions == 0) != (len(tt.mxHosts) == 0) {
+				t.Errorf("server saw %d sessions", sessions)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package verifier
+
+import (
+	"context"
+	"errors"
+
+	emailverifier "github.com/AfterShip/email-verifier"
+)
+
+// GroupByDomain splits emails into batches of indexes that share a domain,
+// each no larger than the number of recipients checked per SMTP session.
+// Batches are ordered by the first appearance of their domain.
+func (v *Verifier) GroupByDomain(emails []string) [][]int {
+	size := v.prober.MaxRecipients
+	var batches [][]int
+	open := make(map[string]int) // domain -> index of its batch being filled
+
+	for i, email := range emails {
+		domain := domainOf(email)
+		b, ok := open[domain]
+		if !ok || len(batches[b]) >= size {
+			b = len(batches)
+			batches = append(batches, nil)
+			open[domain] = b
+		}
+		batches[b] = append(batches[b], i)
+	}
+	return batches
+}
+
+// VerifyBatch verifies several emails, probing addresses at the same domain
+// over a shared SMTP session. Lookups and errors are returned in the order of
+// emails. When the domain's mail servers refuse the shared session, its
+// remaining addresses share the failure; when a recipient breaks it, they
+// are verified on their own with retries. Once ctx is done, the remaining
+// emails fail with its error.
+func (v *Verifier) VerifyBatch(ctx context.Context, emails []string) ([]*Lookup, []error) {
+	lookups := make([]*Lookup, len(emails))
+	errs := make([]error, len(emails))
+
+	// Get a verifier from the pool
+	verifier := v.pool.Get().(*emailverifier.Verifier)
+	defer v.pool.Put(verifier)
+
+	for _, batch := range v.GroupByDomain(emails) {
+		if err := ctx.Err(); err != nil {
+			for _, i := range batch {
+				errs[i] = classifyError(err)
+			}
+			continue
+		}
+		v.verifyDomain(ctx, verifier, emails, batch, lookups, errs)
+	}
+
+	return lookups, errs
+}
+
+// verifyDomain verifies the emails of a batch at one domain, filling in
+// their lookups and errors. The whole batch is bounded by the verify timeout,
+// and the checks of each address by the attempt timeout.
+func (v *Verifier) verifyDomain(ctx context.Context, verifier *emailverifier.Verifier, emails []string, batch []int, lookups []*Lookup, errs []error) {
+	if v.config.VerifyTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.config.VerifyTimeout)
+		defer cancel()
+	}
+
+	// Run the non-SMTP checks, collecting the addresses to probe
+	var probeIdx []int
+	var probeEmails []string
+	for _, i := range batch {
+		lookup, err := v.checkAttempt(ctx, verifier, emails[i])
+		if err != nil {
+			lookups[i], errs[i] = v.VerifyContext(ctx, emails[i])
+			continue
+		}
+		lookups[i] = lookup
+		if lookup.probeable() {
+			probeIdx = append(probeIdx, i)
+			probeEmails = append(probeEmails, lookup.address)
+		}
+	}
+	if len(probeIdx) == 0 {
+		return
+	}
+
+	first := lookups[probeIdx[0]]
+	probes, err := v.prober.ProbeDomain(ctx, first.MXHosts, probeEmails, first.knownCatchAll)
+	for k, i := range probeIdx {
+		if k >= len(probes) || probes[k] == nil {
+			lookups[i], errs[i] = v.probeFailed(ctx, lookups[i], emails[i], err)
+			continue
+		}
+		lookups[i].applyProbe(probes[k])
+		v.domains.learn(lookups[i].Syntax.Domain, probes[k])
+	}
+}
+
+// checkAttempt runs the non-SMTP checks of an email within the attempt
+// timeout
+func (v *Verifier) checkAttempt(ctx context.Context, verifier *emailverifier.Verifier, email string) (*Lookup, error) {
+	if v.config.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.config.AttemptTimeout)
+		defer cancel()
+	}
+	return v.check(ctx, verifier, email)
+}
+
+// probeFailed settles an address a shared SMTP session did not get to after
+// the session failed with err. If the domain's mail servers refused the
+// session, they would refuse the address too, so it fails with the same
+// error, or is greylisted if the refusal was temporary. Once ctx is done,
+// it fails with ctx's error. Otherwise a single recipient broke the session,
+// and the address is verified on its own with retries.
+func (v *Verifier) probeFailed(ctx context.Context, lookup *Lookup, email string, err error) (*Lookup, error) {
+	var sessErr *sessionError
+	switch {
+	case ctx.Err() != nil:
+		return lookup, classifyError(ctx.Err())
+	case errors.As(err, &sessErr):
+		lookup.SMTPCode, lookup.SMTPMessage = smtpReply(err)
+		if isTemporaryError(err) {
+			lookup.Greylisted = true
+			return lookup, nil
+		}
+		return lookup, classifyError(err)
+	default:
+		return v.VerifyContext(ctx, email)
+	}
+}
@@ -0,0 +1,109 @@
+package verifier
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/clau/email_verifier/pkg/config"
+)
+
+// newTestVerifier creates a verifier that resolves names with resolver and
+// probes every mail server at the fake SMTP server
+func newTestVerifier(server *fakeSMTPServer, resolver Resolver) *Verifier {
+	v := New(&config.Config{
+		MaxRetries:     2,
+		InitialBackoff: time.Millisecond,
+		SMTPTimeout:    5 * time.Second,
+		RateLimits:     config.RateLimits{Global: 1000, PerDomain: 1000, PerMX: 1000, Burst: 100},
+		DomainCacheTTL: time.Hour,
+	})
+	v.SetResolver(resolver)
+	v.prober.DialContext = newTestProber(server).DialContext
+	return v
+}
+
+// exampleResolver resolves example.com to the MX host mx.example.com
+func exampleResolver() *FakeResolver {
+	return &FakeResolver{
+		MX:    map[string][]*net.MX{"example.com": {{Host: "mx.example.com.", Pref: 10}}},
+		Hosts: map[string][]string{"mx.example.com": {"127.0.0.1"}},
+	}
+}
+
+func TestVerifyBatchSharesSession(t *testing.T) {
+	server := newFakeSMTPServer(t, replyFor("a@example.com", "250 OK", "550 No such user"))
+	v := newTestVerifier(server, exampleResolver())
+
+	emails := []string{"a@example.com", "b@example.com", "c@example.com"}
+	lookups, errs := v.VerifyBatch(context.Background(), emails)
+	for i, want := range []string{"yes", "no", "no"} {
+		if errs[i] != nil {
+			t.Fatalf("%s error = %v", emails[i], errs[i])
+		}
+		if lookups[i].Reachable != want {
+			t.Errorf("%s reachable = %q, want %q", emails[i], lookups[i].Reachable, want)
+		}
+	}
+	if sessions, _ := server.stats(); sessions != 1 {
+		t.Errorf("server saw %d sessions, want 1", sessions)
+	}
+}
+
+func TestVerifyBatchRefusedSession(t *testing.T) {
+	tests := []struct {
+		name       string
+		greeting   string
+		mail       string
+		greylisted bool
+		category   ErrorCategory
+	}{
+		{name: "busy greeting", greeting: "421 Too many connections", greylisted: true},
+		{name: "temporary MAIL FROM", mail: "451 Try again later", greylisted: true},
+		{name: "rejected MAIL FROM", mail: "550 Sender rejected", category: CategorySMTPPermanent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSMTPServer(t, replyFor("a@example.com", "250 OK", "550 No such user"))
+			server.refuse(tt.greeting, tt.mail)
+			v := newTestVerifier(server, exampleResolver())
+
+			emails := []string{"a@example.com", "b@example.com", "c@example.com"}
+			lookups, errs := v.VerifyBatch(context.Background(), emails)
+			for i, email := range emails {
+				if got := ErrorCategoryOf(errs[i]); got != tt.category {
+					t.Errorf("%s error = %v, want category %q", email, errs[i], tt.category)
+				}
+				if lookups[i] == nil || lookups[i].Greylisted != tt.greylisted {
+					t.Errorf("%s greylisted = %t, want %t", email, lookups[i] != nil && lookups[i].Greylisted, tt.greylisted)
+				}
+			}
+			// The refusal is not retried for each address
+			if sessions, rcpts := server.stats(); sessions != 1 || rcpts != 0 {
+				t.Errorf("server saw %d sessions and %d recipients, want 1 and 0", sessions, rcpts)
+			}
+		})
+	}
+}
+
+func TestVerifyBatchTimeout(t *testing.T) {
+	server := newFakeSMTPServer(t, func(string) string {
+		time.Sleep(2 * time.Second)
+		return "250 OK"
+	})
+	v := newTestVerifier(server, exampleResolver())
+	v.config.VerifyTimeout = 100 * time.Millisecond
+
+	start := time.Now()
+	_, errs := v.VerifyBatch(context.Background(), []string{"a@example.com", "b@example.com"})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("VerifyBatch() took %v, want the verify timeout to stop it", elapsed)
+	}
+	for i, err := range errs {
+		if got := ErrorCategoryOf(err); got != CategoryTimeout {
+			t.Errorf("email %d error = %v, want category %q", i, err, CategoryTimeout)
+		}
+	}
+}
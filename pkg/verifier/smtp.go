@@ -0,0 +1,371 @@
+package verifier
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/clau/email_verifier/pkg/config"
+)
+
+// Default SMTP probe settings used when the config leaves them empty
+const (
+	defaultSMTPPort      = 25
+	defaultHelloName     = "localhost"
+	defaultFromEmail     = "user@example.org"
+	defaultSMTPTimeout   = 10 * time.Second
+	defaultMaxRecipients = 20
+)
+
+// Prober checks whether a mailbox exists by talking SMTP to the domain's MX hosts
+type Prober struct {
+	Port          int
+	HelloName     string
+	FromEmail     string
+	Timeout       time.Duration
+	MaxRecipients int // recipients checked over one SMTP session before reconnecting
+
+	// DialContext opens the connection to a mail server, so tests can point
+	// the prober at a local fake SMTP server
+	DialContext func(ctx context.Context, network, address string) (net.Conn, error)
+
+	// Resolver resolves the names of MX hosts; nil leaves it to DialContext
+	Resolver Resolver
+
+	limiter *rateLimiter
+}
+
+// ProbeResult is the outcome of probing a mailbox over SMTP
+type ProbeResult struct {
+	Host      string // MX host that answered
+	Reachable string // yes, no, unknown
+	CatchAll  bool   // the server also accepted a nonexistent mailbox
+	SMTPUTF8  bool   // the server advertised SMTPUTF8
+	Code      int    // reply code to RCPT TO for the target address
+	Message   string // reply message to RCPT TO for the target address
+}
+
+// session is an open SMTP conversation with one MX host, reused for
+// several recipients at the same domain
+type session struct {
+	prober     *Prober
+	conn       net.Conn
+	client     *smtp.Client
+	stop       func() bool // stops closing conn when the context is done
+	host       string
+	smtputf8   bool // the server accepts internationalized addresses
+	recipients int
+	catchAll   *bool // nil until a random mailbox has been tried
+}
+
+// NewProber creates a prober from the SMTP settings in the config
+func NewProber(cfg *config.Config) *Prober {
+	p := &Prober{
+		Port:          cfg.SMTPPort,
+		HelloName:     cfg.HelloName,
+		FromEmail:     cfg.FromEmail,
+		Timeout:       cfg.SMTPTimeout,
+		MaxRecipients: cfg.MaxRecipientsPerSession,
+		DialContext:   (&net.Dialer{}).DialContext,
+		limiter:       newRateLimiter(cfg.RateLimits),
+	}
+	if p.Port == 0 {
+		p.Port = defaultSMTPPort
+	}
+	if p.HelloName == "" {
+		p.HelloName = defaultHelloName
+	}
+	if p.FromEmail == "" {
+		p.FromEmail = defaultFromEmail
+	}
+	if p.Timeout == 0 {
+		p.Timeout = defaultSMTPTimeout
+	}
+	if p.MaxRecipients <= 0 {
+		p.MaxRecipients = defaultMaxRecipients
+	}
+	return p
+}
+
+// Probe issues RCPT TO for the email and for a random nonexistent mailbox at
+// the same domain, trying each MX host in order until one answers. The
+// random mailbox is skipped when catchAll already holds the answer.
+func (p *Prober) Probe(ctx context.Context, mxHosts []string, email string, catchAll *bool) (*ProbeResult, error) {
+	results, err := p.ProbeDomain(ctx, mxHosts, []string{email}, catchAll)
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// ProbeDomain probes several emails at the same domain, reusing one SMTP
+// session for up to MaxRecipients of them. If a session fails part way, the
+// results gathered so far are returned with the error; the rest are nil.
+// A session the domain's mail servers refused is reported as a
+// *sessionError. The session is abandoned as soon as ctx is done. catchAll
+// is the domain's known catch-all status, or nil if it still has to be
+// checked.
+func (p *Prober) ProbeDomain(ctx context.Context, mxHosts []string, emails []string, catchAll *bool) ([]*ProbeResult, error) {
+	return p.probeUntil(ctx, mxHosts, emails, catchAll, nil)
+}
+
+// probeUntil probes emails like ProbeDomain, stopping after the first one
+// whose result done accepts. The results of the emails after it are nil.
+// A nil done probes every email.
+func (p *Prober) probeUntil(ctx context.Context, mxHosts []string, emails []string, catchAll *bool, done func(*ProbeResult) bool) ([]*ProbeResult, error) {
+	if len(mxHosts) == 0 {
+		return nil, fmt.Errorf("no mx hosts to probe for %s", strings.Join(emails, ", "))
+	}
+
+	results := make([]*ProbeResult, len(emails))
+	var sess *session
+	defer func() {
+		if sess != nil {
+			sess.quit()
+		}
+	}()
+
+	for i, email := range emails {
+		if sess != nil && sess.recipients >= p.MaxRecipients {
+			catchAll = sess.catchAll
+			sess.quit()
+			sess = nil
+		}
+		if sess == nil {
+			var err error
+			if sess, err = p.open(ctx, mxHosts); err != nil {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return results, ctxErr
+				}
+				return results, &sessionError{err: err}
+			}
+			sess.catchAll = catchAll
+		}
+
+		result, err := sess.probe(ctx, email)
+		if err != nil {
+			sess.stop()
+			sess.client.Close()
+			sess = nil
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return results, ctxErr
+			}
+			return results, err
+		}
+		results[i] = result
+		if done != nil && done(result) {
+			break
+		}
+	}
+	return results, nil
+}
+
+// sessionError is a failure to open an SMTP session: connecting, the
+// greeting, HELO or MAIL FROM. Unlike a failed RCPT TO, it would fail the
+// same way for every address at the domain.
+type sessionError struct {
+	err error
+}
+
+func (e *sessionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sessionError) Unwrap() error {
+	return e.err
+}
+
+// open starts a session with the first MX host that answers
+func (p *Prober) open(ctx context.Context, mxHosts []string) (*session, error) {
+	var lastErr error
+	for _, host := range mxHosts {
+		conn, client, err := p.connect(ctx, host)
+		if err == nil {
+			// Abort the conversation when the context is done
+			stop := context.AfterFunc(ctx, func() { conn.Close() })
+			smtputf8, _ := client.Extension("SMTPUTF8")
+			return &session{prober: p, conn: conn, client: client, stop: stop, host: host, smtputf8: smtputf8}, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+
+		if isTemporaryError(err) {
+			p.limiter.slowDown(host)
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// connect dials the MX host and opens the mail transaction
+func (p *Prober) connect(ctx context.Context, host string) (net.Conn, *smtp.Client, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	conn, err := p.dial(dialCtx, host)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn.SetDeadline(p.deadline(ctx))
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if err := client.Hello(p.HelloName); err != nil {
+		client.Close()
+		return nil, nil, err
+	}
+	if err := client.Mail(p.FromEmail); err != nil {
+		client.Close()
+		return nil, nil, err
+	}
+	return conn, client, nil
+}
+
+// dial connects to the SMTP port of an MX host, trying each of its
+// addresses in turn
+func (p *Prober) dial(ctx context.Context, host string) (net.Conn, error) {
+	port := fmt.Sprintf("%d", p.Port)
+	if p.Resolver == nil || net.ParseIP(host) != nil {
+		return p.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	}
+
+	addrs, err := p.Resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		var conn net.Conn
+		if conn, err = p.DialContext(ctx, "tcp", net.JoinHostPort(addr, port)); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// probe checks one recipient, resetting the transaction left by the previous
+// one. An internationalized mailbox is not sent to a server without SMTPUTF8,
+// which could not deliver to it, and is reported unreachable.
+func (s *session) probe(ctx context.Context, email string) (*ProbeResult, error) {
+	if needsSMTPUTF8(email) && !s.smtputf8 {
+		return &ProbeResult{Host: s.host, Reachable: "no"}, nil
+	}
+	if err := s.prober.limiter.wait(ctx, domainOf(email), s.host); err != nil {
+		return nil, err
+	}
+
+	s.conn.SetDeadline(s.prober.deadline(ctx))
+	if s.recipients > 0 {
+		if err := s.client.Reset(); err != nil {
+			return nil, err
+		}
+		if err := s.client.Mail(s.prober.FromEmail); err != nil {
+			return nil, err
+		}
+	}
+	s.recipients++
+
+	var err error
+	result := &ProbeResult{Host: s.host, SMTPUTF8: s.smtputf8}
+	if result.Code, result.Message, err = rcpt(s.client, email); err != nil {
+		return nil, err
+	}
+	result.Reachable = classifyReply(result.Code)
+
+	// Back off from servers that ask us to slow down
+	if isTemporaryReply(result.Code) {
+		s.prober.limiter.slowDown(s.host)
+	} else {
+		s.prober.limiter.speedUp(s.host)
+	}
+
+	// Only an accepted mailbox can hide a catch-all domain, and the answer
+	// holds for every address at the domain
+	if result.Reachable == "yes" {
+		if s.catchAll == nil {
+			randomCode, _, err := rcpt(s.client, randomAddress(email))
+			if err != nil {
+				return nil, err
+			}
+			catchAll := classifyReply(randomCode) == "yes"
+			s.catchAll = &catchAll
+		}
+		if *s.catchAll {
+			result.CatchAll = true
+			result.Reachable = "unknown"
+		}
+	}
+	return result, nil
+}
+
+// quit ends the session politely
+func (s *session) quit() {
+	s.stop()
+	s.client.Quit()
+	s.client.Close()
+}
+
+// deadline returns when the next SMTP command must complete: after the
+// prober's timeout, or earlier if ctx expires first
+func (p *Prober) deadline(ctx context.Context) time.Time {
+	deadline := time.Now().Add(p.Timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		return ctxDeadline
+	}
+	return deadline
+}
+
+// rcpt issues RCPT TO and returns the server's reply code and message.
+// An error means the conversation itself failed, not that the server
+// rejected the recipient.
+func rcpt(client *smtp.Client, email string) (int, string, error) {
+	err := client.Rcpt(email)
+	if err == nil {
+		return 250, "", nil
+	}
+
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code, protoErr.Msg, nil
+	}
+	return 0, "", err
+}
+
+// classifyReply maps an RCPT TO reply code to a reachability verdict
+func classifyReply(code int) string {
+	switch {
+	case code == 250 || code == 251:
+		return "yes"
+	case code >= 500 && code < 600:
+		return "no"
+	default:
+		return "unknown"
+	}
+}
+
+// randomAddress returns a mailbox at the email's domain that should not exist
+func randomAddress(email string) string {
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return hex.EncodeToString(buf) + "@" + domainOf(email)
+}
+
+// domainOf returns the lowercased domain part of an email, in A-labels if it
+// is internationalized
+func domainOf(email string) string {
+	domain := email[strings.LastIndex(email, "@")+1:]
+	if ascii, err := toASCIIDomain(domain); err == nil {
+		return ascii
+	}
+	return strings.ToLower(domain)
+}
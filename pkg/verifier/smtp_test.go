@@ -0,0 +1,250 @@
+package verifier
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	emailverifier "github.com/AfterShip/email-verifier"
+	"github.com/clau/email_verifier/pkg/config"
+)
+
+// fakeSMTPServer is a local SMTP server that answers RCPT TO for each
+// recipient with the reply rcpt gives, such as "250 OK"
+type fakeSMTPServer struct {
+	listener net.Listener
+	rcpt     func(email string) string
+
+	mu       sync.Mutex
+	greeting string   // reply on connect, "220 fake ESMTP" if empty
+	mail     string   // reply to MAIL FROM, "250 OK" if empty
+	sessions int      // connections accepted
+	rcpts    []string // recipients asked about, in order
+}
+
+// newFakeSMTPServer starts a fake server on a local port, stopped when the
+// test ends
+func newFakeSMTPServer(t *testing.T, rcpt func(email string) string) *fakeSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeSMTPServer{listener: listener, rcpt: rcpt}
+	t.Cleanup(func() { listener.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.sessions++
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+// handle talks SMTP over one connection until the client quits
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	reply := func(line string) bool {
+		return text.PrintfLine("%s", line) == nil
+	}
+
+	s.mu.Lock()
+	greeting, mail := s.greeting, s.mail
+	s.mu.Unlock()
+	if greeting == "" {
+		greeting = "220 fake ESMTP"
+	}
+	if mail == "" {
+		mail = "250 OK"
+	}
+	if !reply(greeting) || !strings.HasPrefix(greeting, "2") {
+		return
+	}
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(line)
+		var ok bool
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			ok = reply("250 fake")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			ok = reply(mail)
+		case strings.HasPrefix(command, "RCPT TO:"):
+			email := strings.Trim(line[len("RCPT TO:"):], "<> ")
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, email)
+			s.mu.Unlock()
+			ok = reply(s.rcpt(email))
+		case strings.HasPrefix(command, "RSET"):
+			ok = reply("250 OK")
+		case strings.HasPrefix(command, "QUIT"):
+			reply("221 Bye")
+			return
+		default:
+			ok = reply("502 Command not implemented")
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// refuse makes the server answer new connections with the greeting and
+// MAIL FROM with mail, either left to its default if empty
+func (s *fakeSMTPServer) refuse(greeting, mail string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.greeting, s.mail = greeting, mail
+}
+
+// stats returns the number of connections and RCPT commands the server saw
+func (s *fakeSMTPServer) stats() (sessions, rcpts int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions, len(s.rcpts)
+}
+
+// newTestProber creates a prober whose connections all go to the fake
+// server, whatever MX host they are for, and whose rate limits do not slow
+// tests down
+func newTestProber(server *fakeSMTPServer) *Prober {
+	p := NewProber(&config.Config{
+		SMTPTimeout: 5 * time.Second,
+		RateLimits:  config.RateLimits{Global: 1000, PerDomain: 1000, PerMX: 1000, Burst: 100},
+	})
+	dialer := &net.Dialer{}
+	p.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, network, server.listener.Addr().String())
+	}
+	return p
+}
+
+// replyFor answers RCPT TO with target for the address and random for any
+// other, such as the random mailbox of the catch-all check
+func replyFor(address, target, random string) func(string) string {
+	return func(email string) string {
+		if email == address {
+			return target
+		}
+		return random
+	}
+}
+
+func TestProbe(t *testing.T) {
+	const email = "jane@example.com"
+	tests := []struct {
+		name       string
+		rcpt       func(string) string
+		reachable  string
+		catchAll   bool
+		code       int
+		greylisted bool
+	}{
+		{
+			name:      "catch-all",
+			rcpt:      replyFor(email, "250 OK", "250 OK"),
+			reachable: "unknown",
+			catchAll:  true,
+			code:      250,
+		},
+		{
+			name:      "mailbox exists",
+			rcpt:      replyFor(email, "250 OK", "550 No such user"),
+			reachable: "yes",
+			code:      250,
+		},
+		{
+			name:      "mailbox not found",
+			rcpt:      replyFor(email, "550 No such user", "550 No such user"),
+			reachable: "no",
+			code:      550,
+		},
+		{
+			name:       "greylisted",
+			rcpt:       replyFor(email, "451 Try again later", "451 Try again later"),
+			reachable:  "unknown",
+			code:       451,
+			greylisted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSMTPServer(t, tt.rcpt)
+			probe, err := newTestProber(server).Probe(context.Background(), []string{"mx.example.com"}, email, nil)
+			if err != nil {
+				t.Fatalf("Probe() error = %v", err)
+			}
+			if probe.Reachable != tt.reachable || probe.CatchAll != tt.catchAll || probe.Code != tt.code {
+				t.Errorf("Probe() = reachable %q, catch-all %t, code %d; want %q, %t, %d",
+					probe.Reachable, probe.CatchAll, probe.Code, tt.reachable, tt.catchAll, tt.code)
+			}
+			if probe.Host != "mx.example.com" {
+				t.Errorf("Probe() host = %q, want mx.example.com", probe.Host)
+			}
+
+			lookup := &Lookup{Result: &emailverifier.Result{}}
+			lookup.applyProbe(probe)
+			if lookup.Greylisted != tt.greylisted {
+				t.Errorf("greylisted = %t, want %t", lookup.Greylisted, tt.greylisted)
+			}
+		})
+	}
+}
+
+func TestProbeDomainReusesSession(t *testing.T) {
+	server := newFakeSMTPServer(t, replyFor("a@example.com", "250 OK", "550 No such user"))
+	prober := newTestProber(server)
+	prober.MaxRecipients = 2
+
+	emails := []string{"a@example.com", "b@example.com", "c@example.com"}
+	probes, err := prober.ProbeDomain(context.Background(), []string{"mx.example.com"}, emails, nil)
+	if err != nil {
+		t.Fatalf("ProbeDomain() error = %v", err)
+	}
+	for i, want := range []string{"yes", "no", "no"} {
+		if probes[i].Reachable != want {
+			t.Errorf("%s reachable = %q, want %q", emails[i], probes[i].Reachable, want)
+		}
+	}
+	// The catch-all check is made once, and carried over to the second session
+	if sessions, rcpts := server.stats(); sessions != 2 || rcpts != 4 {
+		t.Errorf("server saw %d sessions and %d recipients, want 2 and 4", sessions, rcpts)
+	}
+}
+
+func TestProbeFallsBackToNextMX(t *testing.T) {
+	server := newFakeSMTPServer(t, replyFor("jane@example.com", "250 OK", "550 No such user"))
+	prober := newTestProber(server)
+	dial := prober.DialContext
+	prober.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if host, _, _ := net.SplitHostPort(address); host == "mx1.example.com" {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: &net.AddrError{Err: "refused", Addr: address}}
+		}
+		return dial(ctx, network, address)
+	}
+
+	probe, err := prober.Probe(context.Background(), []string{"mx1.example.com", "mx2.example.com"}, "jane@example.com", nil)
+	if err != nil {
+		t.Fatalf("Probe() error = %v", err)
+	}
+	if probe.Host != "mx2.example.com" || probe.Reachable != "yes" {
+		t.Errorf("Probe() = host %q, reachable %q; want mx2.example.com, yes", probe.Host, probe.Reachable)
+	}
+}
@@ -1,9 +1,13 @@
 package verifier
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math"
+	"net/textproto"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -15,174 +19,521 @@ import (
 
 // Result represents the result of email verification
 type Result struct {
-	Email              string `json:"email"`
-	VerificationStatus string `json:"verification_status"` // valid, invalid, risky
-	ConfidenceScore    int    `json:"confidence_score"`    // 0 to 100
+	Email              string        `json:"email"`
+	EmailASCII         string        `json:"email_ascii"`   // domain in A-labels, as used for DNS and SMTP
+	EmailUnicode       string        `json:"email_unicode"` // domain in U-labels
+	NormalizedEmail    string        `json:"normalized_email"`
+	HasSubaddress      bool          `json:"has_subaddress"`
+	VerificationStatus string        `json:"verification_status"` // valid, invalid, risky
+	ConfidenceScore    int           `json:"confidence_score"`    // 0 to 100
+	Checks             *Checks       `json:"checks,omitempty"`
+	MXHosts            []string      `json:"mx_hosts,omitempty"`
+	SMTPCode           int           `json:"smtp_code,omitempty"`
+	SMTPMessage        string        `json:"smtp_message,omitempty"`
+	Suggestion         string        `json:"suggestion,omitempty"`
+	Reasons            []Reason      `json:"reasons"`
+	Lead               *LeadChecks   `json:"lead,omitempty"`
+	DomainInfo         *MailPolicies `json:"domain_info,omitempty"` // the domain's SPF, DMARC, MTA-STS and TLS-RPT records
+	ErrorCategory      ErrorCategory `json:"error_category,omitempty"`
 }
 
-// Verifier handles email verification operations
-type Verifier struct {
-	config      *config.Config
-	pool        *sync.Pool
-	rateLimiter *rateLimiter
-	mu          sync.Mutex // Mutex for thread-safe operations
-}
+// Checks holds the outcome of each individual verification check
+type Checks struct {
+	SyntaxValid  bool   `json:"syntax_valid"`
+	HasMxRecords bool   `json:"has_mx_records"`
+	Reachable    string `json:"reachable"` // yes, no, unknown
+	Disposable   bool   `json:"disposable"`
+	RoleAccount  bool   `json:"role_account"`
+	FreeProvider bool   `json:"free_provider"`
+	CatchAll     bool   `json:"catch_all"`
+	Greylisted   bool   `json:"greylisted"`
+	SMTPUTF8     bool   `json:"smtputf8"` // the mail server accepts internationalized addresses
 
-type rateLimiter struct {
-	mu       sync.Mutex
-	lastCall time.Time
-	interval time.Duration
+	// How mail reaches the domain: mx, implicit_mx (no MX, so its A/AAAA
+	// address), null_mx (it accepts no mail) or none (no MX and no address)
+	MailRoute string `json:"mail_route,omitempty"`
 }
 
-func newRateLimiter(interval time.Duration) *rateLimiter {
-	return &rateLimiter{
-		interval: interval,
-	}
+// Lookup is the raw outcome of verifying an email, before scoring
+type Lookup struct {
+	*emailverifier.Result
+	MXHosts     []string
+	CatchAll    bool
+	Greylisted  bool // the mail server temporarily rejected the check
+	SMTPUTF8    bool // the mail server advertised SMTPUTF8
+	SMTPCode    int
+	SMTPMessage string
+	Policies    *MailPolicies // the domain's mail policies, nil if not looked up
+	MailRoute   string        // how mail reaches the domain: mx, implicit_mx, null_mx or none
+
+	address       string // the email with its domain in A-labels, as probed
+	knownCatchAll *bool  // the domain's catch-all status from the domain cache
 }
 
-func (rl *rateLimiter) wait() {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+// Verifier handles email verification operations
+type Verifier struct {
+	config  *config.Config
+	pool    *sync.Pool
+	prober  *Prober
+	domains *domainCache
+	mu      sync.Mutex // Mutex for thread-safe operations
 
-	now := time.Now()
-	if elapsed := now.Sub(rl.lastCall); elapsed < rl.interval {
-		time.Sleep(rl.interval - elapsed)
-	}
-	rl.lastCall = time.Now()
+	// resolver looks up the domain's MX records and mail policies, and the
+	// prober's MX hosts
+	resolver Resolver
 }
 
 // New creates a new email verifier instance
 func New(cfg *config.Config) *Verifier {
-	return &Verifier{
+	v := &Verifier{
 		config: cfg,
 		pool: &sync.Pool{
 			New: func() interface{} {
+				// SMTP checks are done by our own prober
 				return emailverifier.NewVerifier().
-					EnableSMTPCheck().
 					EnableDomainSuggest()
 			},
 		},
-		rateLimiter: newRateLimiter(100 * time.Millisecond), // 10 requests per second
+		prober:  NewProber(cfg),
+		domains: newDomainCache(cfg.DomainCacheTTL),
 	}
+	v.SetResolver(NewResolver(cfg.DNS))
+	return v
+}
+
+// SetResolver replaces the resolver used for every DNS lookup, so tests can
+// answer from a FakeResolver. It must be called before verifying.
+func (v *Verifier) SetResolver(resolver Resolver) {
+	v.resolver = resolver
+	v.prober.Resolver = resolver
 }
 
-// VerifyWithRetry attempts to verify an email with retries
-func (v *Verifier) VerifyWithRetry(email string) (*emailverifier.Result, error) {
-	var result *emailverifier.Result
+// DomainCacheStats returns how many domain lookups were served from the
+// domain cache and how many had to be resolved
+func (v *Verifier) DomainCacheStats() (hits, misses int) {
+	return v.domains.stats()
+}
+
+// VerifyContext attempts to verify an email with retries, giving up when ctx
+// is done or the configured total timeout passes.
+// When verification fails, the returned lookup holds whatever was learned
+// before the failure and may be nil.
+func (v *Verifier) VerifyContext(ctx context.Context, email string) (*Lookup, error) {
+	var lookup *Lookup
 	var err error
 
+	if v.config.VerifyTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.config.VerifyTimeout)
+		defer cancel()
+	}
+
 	// Get a verifier from the pool
 	verifier := v.pool.Get().(*emailverifier.Verifier)
 	defer v.pool.Put(verifier)
 
 	for attempt := 0; attempt <= v.config.MaxRetries; attempt++ {
-		// Apply rate limiting
-		v.rateLimiter.wait()
-
-		// Perform verification with proper error handling
-		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					err = fmt.Errorf("panic during verification: %v", r)
-				}
-			}()
-			result, err = verifier.Verify(email)
-		}()
-
+		lookup, err = v.verify(ctx, verifier, email)
 		if err == nil {
-			return result, nil
+			return lookup, nil
 		}
 
-		if isRetryableError(err) {
+		if isRetryableError(err) && ctx.Err() == nil {
 			backoffDuration := v.config.InitialBackoff * time.Duration(math.Pow(2, float64(attempt)))
 			log.Printf("Attempt %d: Error verifying email %s: %v. Retrying in %v...", attempt+1, email, err, backoffDuration)
-			time.Sleep(backoffDuration)
+			select {
+			case <-time.After(backoffDuration):
+			case <-ctx.Done():
+				return lookup, classifyError(ctx.Err())
+			}
 		} else {
-			return nil, err
+			return lookup, err
+		}
+	}
+
+	return lookup, err
+}
+
+// verify performs a single verification attempt: the AfterShip checks
+// followed by an SMTP probe of the domain's MX hosts
+func (v *Verifier) verify(ctx context.Context, verifier *emailverifier.Verifier, email string) (*Lookup, error) {
+	if v.config.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, v.config.AttemptTimeout)
+		defer cancel()
+	}
+
+	lookup, err := v.check(ctx, verifier, email)
+	if err == nil && lookup.probeable() {
+		var probe *ProbeResult
+		probe, err = v.prober.Probe(ctx, lookup.MXHosts, lookup.address, lookup.knownCatchAll)
+		lookup.applyProbe(probe)
+		v.domains.learn(lookup.Syntax.Domain, probe)
+
+		// A temporary refusal is greylisting, not a failed verification
+		if isTemporaryError(err) {
+			lookup.Greylisted = true
+			lookup.SMTPCode, lookup.SMTPMessage = smtpReply(err)
+			err = nil
+		}
+	}
+	if err != nil && lookup != nil {
+		lookup.SMTPCode, lookup.SMTPMessage = smtpReply(err)
+	}
+
+	return lookup, classifyError(err)
+}
+
+// check runs the AfterShip checks (syntax, disposable, role, free provider,
+// MX and domain suggestion) without touching SMTP. The library takes no
+// context, so the checks run in the background and are abandoned if ctx is
+// done first.
+func (v *Verifier) check(ctx context.Context, verifier *emailverifier.Verifier, email string) (*Lookup, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type checked struct {
+		lookup *Lookup
+		err    error
+	}
+	done := make(chan checked, 1)
+
+	go func() {
+		// Perform verification with proper error handling
+		defer func() {
+			if r := recover(); r != nil {
+				done <- checked{err: &Error{Category: CategoryPanic, Err: fmt.Errorf("panic during verification: %v", r)}}
+			}
+		}()
+
+		lookup, err := v.lookup(ctx, verifier, email)
+		done <- checked{lookup: lookup, err: err}
+	}()
+
+	select {
+	case c := <-done:
+		return c.lookup, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// probeable reports whether the email is worth probing over SMTP
+func (l *Lookup) probeable() bool {
+	return l.Syntax.Valid && !l.Disposable && len(l.MXHosts) > 0
+}
+
+// applyProbe records the outcome of an SMTP probe on the lookup
+func (l *Lookup) applyProbe(probe *ProbeResult) {
+	if probe == nil {
+		return
+	}
+	l.Reachable = probe.Reachable
+	l.CatchAll = probe.CatchAll
+	l.Greylisted = isTemporaryReply(probe.Code)
+	l.SMTPUTF8 = probe.SMTPUTF8
+	l.SMTPCode, l.SMTPMessage = probe.Code, probe.Message
+}
+
+// lookup runs the AfterShip checks on an email, taking the facts about its
+// domain from the domain cache. Internationalized domains are checked in
+// their A-label form.
+func (v *Verifier) lookup(ctx context.Context, verifier *emailverifier.Verifier, email string) (*Lookup, error) {
+	address, _ := AddressForms(email)
+	syntax := parseAddress(verifier, address)
+	lookup := &Lookup{Result: &emailverifier.Result{
+		Email:     email,
+		Reachable: "unknown",
+		Syntax:    syntax,
+	}, address: address}
+	if !syntax.Valid {
+		return lookup, nil
+	}
+	lookup.RoleAccount = verifier.IsRoleAccount(syntax.Username)
+
+	info, err := v.domains.get(syntax.Domain, func() (DomainInfo, error) {
+		// Every address at the domain waits for this load, so it must not
+		// fail for all of them when the one that started it gives up
+		ctx, cancel := v.loadContext(ctx)
+		defer cancel()
+		return v.loadDomain(ctx, verifier, syntax.Domain)
+	})
+	lookup.Free = info.Free
+	lookup.Disposable = info.Disposable
+	lookup.HasMxRecords = info.HasMxRecords
+	lookup.MXHosts = info.MXHosts
+	lookup.MailRoute = info.MailRoute
+	lookup.Suggestion = info.Suggestion
+	lookup.Policies = info.Policies
+	lookup.knownCatchAll = info.CatchAll
+	return lookup, err
+}
+
+// loadContext returns the context for loading a domain's facts on behalf of
+// every address at the domain: one that is not canceled with ctx, bounded by
+// the attempt timeout instead
+func (v *Verifier) loadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = context.WithoutCancel(ctx)
+	if v.config.AttemptTimeout > 0 {
+		return context.WithTimeout(ctx, v.config.AttemptTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// loadDomain resolves the facts about a domain, including its mail policies.
+// Disposable domains are not looked up any further.
+func (v *Verifier) loadDomain(ctx context.Context, verifier *emailverifier.Verifier, domain string) (DomainInfo, error) {
+	info := DomainInfo{
+		Free:       verifier.IsFreeDomain(domain),
+		Disposable: verifier.IsDisposable(domain),
+	}
+	if info.Disposable {
+		return info, nil
+	}
+	info.Suggestion = verifier.SuggestDomain(domain)
+
+	if err := v.loadMailRoute(ctx, &info, domain); err != nil {
+		return info, err
+	}
+	info.Policies = lookupPolicies(ctx, v.resolver, domain)
+	return info, ctx.Err()
+}
+
+// loadMailRoute finds the hosts that receive a domain's mail. Without MX
+// records, mail goes to the domain's own address if it has one (RFC 5321
+// implicit MX). A single MX record for "." is a null MX: the domain accepts
+// no mail (RFC 7505). A domain with neither MX records nor an address, or
+// that does not exist, has no route for mail; only failed lookups are errors.
+func (v *Verifier) loadMailRoute(ctx context.Context, info *DomainInfo, domain string) error {
+	records, err := v.resolver.LookupMX(ctx, domain)
+	if len(records) == 1 && strings.TrimSuffix(records[0].Host, ".") == "" {
+		info.MailRoute = "null_mx"
+		return nil
+	}
+	for _, record := range records {
+		if host := strings.TrimSuffix(record.Host, "."); host != "" {
+			info.MXHosts = append(info.MXHosts, host)
+		}
+	}
+	if len(info.MXHosts) > 0 {
+		info.HasMxRecords, info.MailRoute = true, "mx"
+		return nil
+	}
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+
+	addrs, hostErr := v.resolver.LookupHost(ctx, domain)
+	switch {
+	case len(addrs) > 0:
+		info.MXHosts, info.MailRoute = []string{domain}, "implicit_mx"
+		return nil
+	case hostErr != nil && !isNotFound(hostErr):
+		return hostErr
+	default:
+		info.MailRoute = "none"
+		return nil
+	}
+}
+
+// smtpReply extracts the SMTP reply code and message from a verification error
+func smtpReply(err error) (int, string) {
+	if err == nil {
+		return 0, ""
+	}
+
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code, protoErr.Msg
+	}
+
+	// AfterShip wraps SMTP errors, keeping the server reply in Details
+	var lookupErr *emailverifier.LookupError
+	if errors.As(err, &lookupErr) {
+		details := strings.TrimSpace(lookupErr.Details)
+		if len(details) >= 3 {
+			if code, convErr := strconv.Atoi(details[:3]); convErr == nil {
+				return code, strings.TrimSpace(details[3:])
+			}
 		}
+		return 0, lookupErr.Message
+	}
+
+	return 0, ""
+}
+
+// NewResult starts the result of an email with the other forms of its address
+func NewResult(email string) Result {
+	asciiForm, unicodeForm := AddressForms(email)
+	normalized, subaddress := Normalize(email)
+	return Result{
+		Email:           email,
+		EmailASCII:      asciiForm,
+		EmailUnicode:    unicodeForm,
+		NormalizedEmail: normalized,
+		HasSubaddress:   subaddress,
 	}
+}
 
-	return nil, err
+// As returns the result for another spelling of the same mailbox, such as an
+// alias or the address in other case, reported as written there
+func (r Result) As(email string) Result {
+	forms := NewResult(email)
+	r.Email, r.EmailASCII, r.EmailUnicode, r.HasSubaddress = email, forms.EmailASCII, forms.EmailUnicode, forms.HasSubaddress
+	return r
+}
+
+// FailedResult builds the result for an email whose verification could not
+// complete, keeping the details gathered before the failure
+func FailedResult(email, status string, lookup *Lookup, err error) Result {
+	result := NewResult(email)
+	result.VerificationStatus = status
+	result.ConfidenceScore = 0
+	result.ErrorCategory = ErrorCategoryOf(err)
+	if lookup != nil {
+		lookup.describe(&result)
+	}
+	result.addReason(ReasonVerificationFailed, 0)
+	return result
+}
+
+// describe copies the verification details from the lookup into the result
+func (l *Lookup) describe(result *Result) {
+	result.Checks = &Checks{
+		SyntaxValid:  l.Syntax.Valid,
+		HasMxRecords: l.HasMxRecords,
+		Reachable:    l.Reachable,
+		Disposable:   l.Disposable,
+		RoleAccount:  l.RoleAccount,
+		FreeProvider: l.Free,
+		CatchAll:     l.CatchAll,
+		Greylisted:   l.Greylisted,
+		SMTPUTF8:     l.SMTPUTF8,
+		MailRoute:    l.MailRoute,
+	}
+	result.MXHosts = l.MXHosts
+	result.SMTPCode = l.SMTPCode
+	result.SMTPMessage = l.SMTPMessage
+	result.Suggestion = l.Suggestion
+	result.DomainInfo = l.Policies
 }
 
 // DetermineStatus calculates the verification status and confidence score
-func (v *Verifier) DetermineStatus(result *emailverifier.Result, email string) (string, int) {
-	if result == nil {
+func (v *Verifier) DetermineStatus(lookup *Lookup, email string) Result {
+	if lookup == nil || lookup.Result == nil {
 		log.Printf("Warning: Nil result for email %s. Marking as invalid.", email)
-		return "invalid", 0
+		return FailedResult(email, "invalid", nil, nil)
 	}
 
+	result := NewResult(email)
+	lookup.describe(&result)
+
 	fmt.Printf("\n--- Verification Details for %s ---\n", email)
-	fmt.Printf("Syntax Valid: %t\n", result.Syntax.Valid)
-	fmt.Printf("Disposable: %t\n", result.Disposable)
-	fmt.Printf("Has MX Records: %t\n", result.HasMxRecords)
-	fmt.Printf("Reachable: %s\n", result.Reachable)
-	fmt.Printf("Role Account: %t\n", result.RoleAccount)
-	fmt.Printf("Free Provider: %t\n", result.Free)
-	fmt.Printf("Suggestion: %s\n", result.Suggestion)
+	fmt.Printf("Syntax Valid: %t\n", lookup.Syntax.Valid)
+	fmt.Printf("Disposable: %t\n", lookup.Disposable)
+	fmt.Printf("Has MX Records: %t\n", lookup.HasMxRecords)
+	fmt.Printf("Reachable: %s\n", lookup.Reachable)
+	fmt.Printf("Catch-All: %t\n", lookup.CatchAll)
+	fmt.Printf("Role Account: %t\n", lookup.RoleAccount)
+	fmt.Printf("Free Provider: %t\n", lookup.Free)
+	fmt.Printf("Suggestion: %s\n", lookup.Suggestion)
 
 	confidenceScore := 50 // Base confidence
 
 	// Rigid Invalid Conditions (highest priority)
-	if !result.Syntax.Valid {
+	if !lookup.Syntax.Valid {
 		fmt.Println("Status: invalid - Invalid Syntax")
-		return "invalid", 0
+		result.addReason(ReasonSyntaxInvalid, 0)
+		result.VerificationStatus, result.ConfidenceScore = "invalid", 0
+		return result
+	}
+	if lookup.MailRoute == "null_mx" {
+		fmt.Println("Status: invalid - Null MX")
+		result.addReason(ReasonNullMX, 0)
+		result.VerificationStatus, result.ConfidenceScore = "invalid", 0
+		return result
+	}
+	if lookup.MailRoute == "none" {
+		fmt.Println("Status: invalid - No MX Records")
+		result.addReason(ReasonNoMxRecords, 0)
+		result.VerificationStatus, result.ConfidenceScore = "invalid", 0
+		return result
 	}
-	if result.Reachable == "no" {
+	if lookup.Reachable == "no" && needsSMTPUTF8(email) && !lookup.SMTPUTF8 {
+		fmt.Println("Status: invalid - SMTPUTF8 not supported")
+		result.addReason(ReasonSMTPUTF8Unsupported, 0)
+		result.VerificationStatus, result.ConfidenceScore = "invalid", 0
+		return result
+	}
+	if lookup.Reachable == "no" {
 		fmt.Println("Status: invalid - Reachable: no")
-		return "invalid", 0
+		result.addReason(ReasonMailboxNotFound, 0)
+		result.VerificationStatus, result.ConfidenceScore = "invalid", 0
+		return result
 	}
-	if result.Disposable {
+	if lookup.Disposable {
 		fmt.Println("Status: invalid - Disposable Email")
-		return "invalid", 10
+		result.addReason(ReasonDisposable, 0)
+		result.VerificationStatus, result.ConfidenceScore = "invalid", 0
+		return result
 	}
 
 	// Positive signals
-	if result.HasMxRecords {
-		confidenceScore += v.config.ScoringWeights.HasMxRecords
+	switch {
+	case lookup.HasMxRecords:
+		confidenceScore += result.addReason(ReasonMxRecordsFound, v.config.ScoringWeights.HasMxRecords)
+	case lookup.MailRoute == "implicit_mx":
+		confidenceScore += result.addReason(ReasonImplicitMX, v.config.ScoringWeights.ImplicitMX)
 	}
-	if result.Reachable == "yes" {
-		confidenceScore += v.config.ScoringWeights.ReachableYes
+	if lookup.Reachable == "yes" {
+		confidenceScore += result.addReason(ReasonMailboxExists, v.config.ScoringWeights.ReachableYes)
 	}
 
-	// Negative signals
-	if result.Reachable == "unknown" {
-		confidenceScore += v.config.ScoringWeights.ReachableUnknown
+	// Negative signals. A catch-all cannot confirm the mailbox either, so it
+	// is scored by its own weight alone
+	if lookup.Reachable == "unknown" && !lookup.CatchAll {
+		confidenceScore += result.addReason(ReasonReachabilityUnknown, v.config.ScoringWeights.ReachableUnknown)
+	}
+	if lookup.Greylisted {
+		result.addReason(ReasonGreylisted, 0)
+	}
+	if lookup.CatchAll {
+		confidenceScore += result.addReason(ReasonCatchAll, v.config.ScoringWeights.CatchAll)
 	}
-	if result.RoleAccount {
-		confidenceScore += v.config.ScoringWeights.RoleAccount
+	if lookup.RoleAccount {
+		confidenceScore += result.addReason(ReasonRoleAccount, v.config.ScoringWeights.RoleAccount)
 	}
-	if result.Free {
-		confidenceScore += v.config.ScoringWeights.FreeProvider
+	if lookup.Free {
+		confidenceScore += result.addReason(ReasonFreeProvider, v.config.ScoringWeights.FreeProvider)
 	}
-	if result.Suggestion != "" {
-		confidenceScore += v.config.ScoringWeights.Suggestion
+	if lookup.Suggestion != "" {
+		confidenceScore += result.addReason(ReasonSuggestionAvailable, v.config.ScoringWeights.Suggestion)
+	}
+	if policies := lookup.Policies; policies != nil {
+		// Domains publishing no SPF or DMARC are often abandoned or spammy
+		if policies.SPF == nil && policies.resolved("spf") {
+			confidenceScore += result.addReason(ReasonNoSPF, v.config.ScoringWeights.NoSPF)
+		}
+		if policies.DMARC == nil && policies.resolved("dmarc") {
+			confidenceScore += result.addReason(ReasonNoDMARC, v.config.ScoringWeights.NoDMARC)
+		}
 	}
 
 	confidenceScore = utils.Max(0, utils.Min(confidenceScore, 100))
 
-	var verificationStatus string
+	result.VerificationStatus, result.ConfidenceScore = v.status(confidenceScore), confidenceScore
+	return result
+}
+
+// status maps a confidence score to a verification status
+func (v *Verifier) status(confidenceScore int) string {
 	switch {
 	case confidenceScore >= v.config.ValidThreshold:
-		verificationStatus = "valid"
+		return "valid"
 	case confidenceScore >= v.config.RiskyThreshold:
-		verificationStatus = "risky"
+		return "risky"
 	default:
-		verificationStatus = "invalid"
+		return "invalid"
 	}
-
-	return verificationStatus, confidenceScore
-}
-
-// isRetryableError checks if an error is considered retryable
-func isRetryableError(err error) bool {
-	if err == nil {
-		return false
-	}
-	errMsg := err.Error()
-	return strings.Contains(errMsg, "timeout") ||
-		strings.Contains(errMsg, "i/o error") ||
-		strings.Contains(errMsg, "connection refused") ||
-		strings.Contains(errMsg, "no such host") ||
-		strings.Contains(errMsg, "network is unreachable")
 }
@@ -0,0 +1,173 @@
+package verifier
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	emailverifier "github.com/AfterShip/email-verifier"
+	"github.com/clau/email_verifier/pkg/config"
+)
+
+// scoringConfig is a config with every scoring weight set
+func scoringConfig() *config.Config {
+	return &config.Config{
+		ValidThreshold: 80,
+		RiskyThreshold: 60,
+		ScoringWeights: config.ScoringWeights{
+			HasMxRecords:     30,
+			ImplicitMX:       15,
+			ReachableYes:     50,
+			ReachableUnknown: -20,
+			RoleAccount:      -15,
+			FreeProvider:     -10,
+			Suggestion:       -25,
+			CatchAll:         -20,
+		},
+	}
+}
+
+// exampleLookup is the lookup of a deliverable address at example.com, which
+// the test cases change to give each signal
+func exampleLookup() *Lookup {
+	return &Lookup{
+		Result: &emailverifier.Result{
+			Syntax:       emailverifier.Syntax{Username: "jane", Domain: "example.com", Valid: true},
+			HasMxRecords: true,
+			Reachable:    "yes",
+		},
+		MXHosts:   []string{"mx.example.com"},
+		MailRoute: "mx",
+	}
+}
+
+func TestDetermineStatusForcedInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*Lookup)
+		reason ReasonCode
+	}{
+		{"invalid syntax", func(l *Lookup) { l.Syntax.Valid = false }, ReasonSyntaxInvalid},
+		{"null MX", func(l *Lookup) { l.MailRoute, l.HasMxRecords, l.MXHosts = "null_mx", false, nil }, ReasonNullMX},
+		{"no mail route", func(l *Lookup) { l.MailRoute, l.HasMxRecords, l.MXHosts = "none", false, nil }, ReasonNoMxRecords},
+		{"mailbox not found", func(l *Lookup) { l.Reachable = "no" }, ReasonMailboxNotFound},
+		{"disposable", func(l *Lookup) { l.Disposable = true }, ReasonDisposable},
+	}
+
+	v := New(scoringConfig())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookup := exampleLookup()
+			tt.change(lookup)
+
+			result := v.DetermineStatus(lookup, "jane@example.com")
+			if result.VerificationStatus != "invalid" || result.ConfidenceScore != 0 {
+				t.Errorf("DetermineStatus() = %s %d, want invalid 0", result.VerificationStatus, result.ConfidenceScore)
+			}
+			if len(result.Reasons) != 1 || result.Reasons[0] != (Reason{Code: tt.reason}) {
+				t.Errorf("reasons = %v, want %s with weight 0", result.Reasons, tt.reason)
+			}
+		})
+	}
+}
+
+func TestDetermineStatusUnconfirmed(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(*Lookup)
+		score   int
+		reasons []Reason
+	}{
+		{
+			name:    "reachability unknown",
+			change:  func(l *Lookup) { l.Reachable = "unknown" },
+			score:   60,
+			reasons: []Reason{{ReasonMxRecordsFound, 30}, {ReasonReachabilityUnknown, -20}},
+		},
+		{
+			name:    "catch-all",
+			change:  func(l *Lookup) { l.Reachable, l.CatchAll = "unknown", true },
+			score:   60,
+			reasons: []Reason{{ReasonMxRecordsFound, 30}, {ReasonCatchAll, -20}},
+		},
+	}
+
+	v := New(scoringConfig())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookup := exampleLookup()
+			tt.change(lookup)
+
+			result := v.DetermineStatus(lookup, "jane@example.com")
+			if result.ConfidenceScore != tt.score {
+				t.Errorf("score = %d, want %d", result.ConfidenceScore, tt.score)
+			}
+			if !slices.Equal(result.Reasons, tt.reasons) {
+				t.Errorf("reasons = %v, want %v", result.Reasons, tt.reasons)
+			}
+		})
+	}
+}
+
+// blockingResolver holds MX lookups until released, unless their context is
+// done first
+type blockingResolver struct {
+	Resolver
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *blockingResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	r.started <- struct{}{}
+	select {
+	case <-r.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return r.Resolver.LookupMX(ctx, name)
+}
+
+func TestDomainLoadOutlivesCaller(t *testing.T) {
+	server := newFakeSMTPServer(t, replyFor("b@example.com", "250 OK", "550 No such user"))
+	resolver := &blockingResolver{Resolver: exampleResolver(), started: make(chan struct{}, 1), release: make(chan struct{})}
+	v := newTestVerifier(server, resolver)
+
+	// The first address starts loading the domain, then gives up
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := v.VerifyContext(ctx, "a@example.com")
+		first <- err
+	}()
+	<-resolver.started
+
+	// The second waits for that load
+	type verified struct {
+		lookup *Lookup
+		err    error
+	}
+	second := make(chan verified)
+	go func() {
+		lookup, err := v.VerifyContext(context.Background(), "b@example.com")
+		second <- verified{lookup, err}
+	}()
+	for hits, _ := v.DomainCacheStats(); hits == 0; hits, _ = v.DomainCacheStats() {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-first; ErrorCategoryOf(err) != CategoryCanceled {
+		t.Errorf("first VerifyContext() error = %v, want canceled", err)
+	}
+	close(resolver.release)
+
+	got := <-second
+	if got.err != nil {
+		t.Fatalf("second VerifyContext() error = %v", got.err)
+	}
+	if got.lookup.Reachable != "yes" {
+		t.Errorf("second reachable = %q, want yes", got.lookup.Reachable)
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	goio "io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clau/email_verifier/pkg/cache"
+	"github.com/clau/email_verifier/pkg/config"
+	"github.com/clau/email_verifier/pkg/io"
+	"github.com/clau/email_verifier/pkg/verifier"
+)
+
+// streamWindow is how many rows are read ahead and grouped by domain at a
+// time when streaming. At most maxOutstandingRows rows are held in memory
+// between being read and being written.
+const (
+	streamWindow       = 1000
+	maxOutstandingRows = 4 * streamWindow
+)
+
+// maxSharedResults is how many results are kept while streaming so repeated
+// addresses reuse the result of their first occurrence. An address whose
+// result has been evicted is verified again.
+const (
+	maxSharedResults = 100000
+	sharedResultTTL  = 24 * time.Hour
+)
+
+// maxFirstRows is how many addresses are remembered while streaming to
+// report the row they first appeared in. A repeat of an address forgotten
+// since is not reported as a duplicate.
+const maxFirstRows = maxSharedResults
+
+// sharedResults lets the occurrences of an address share one verification
+// while streaming
+type sharedResults struct {
+	mu      sync.Mutex
+	results *cache.Memory     // normalized email -> result
+	waiting map[string][]task // normalized email being verified -> later occurrences
+}
+
+func newSharedResults() *sharedResults {
+	return &sharedResults{
+		results: cache.NewMemory(maxSharedResults),
+		waiting: make(map[string][]task),
+	}
+}
+
+// claim looks up the address of a task. It returns the result of an earlier
+// occurrence if there is one. Otherwise, if an earlier occurrence is being
+// verified, the task waits for it; if not, verify is true and the task's
+// result must be passed to settle.
+func (s *sharedResults) claim(t task) (result verifier.Result, found, verify bool) {
+	key := verifier.NormalizeEmail(t.email)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if result, ok := s.results.Get(key); ok {
+		return shareResult(result, t.email), true, false
+	}
+	if waiting, ok := s.waiting[key]; ok {
+		s.waiting[key] = append(waiting, t)
+		return verifier.Result{}, false, false
+	}
+	s.waiting[key] = nil
+	return verifier.Result{}, false, true
+}
+
+// settle records the result of a claimed task and returns the occurrences
+// that were waiting for it, with the result
+func (s *sharedResults) settle(t task, result verifier.Result) []outcome {
+	key := verifier.NormalizeEmail(t.email)
+
+	s.mu.Lock()
+	waiting := s.waiting[key]
+	delete(s.waiting, key)
+	if result.VerificationStatus != "not_checked" {
+		s.results.Set(key, result, sharedResultTTL)
+	}
+	s.mu.Unlock()
+
+	outcomes := make([]outcome, len(waiting))
+	for i, w := range waiting {
+		outcomes[i] = outcome{task: w, result: shareResult(result, w.email), shared: true}
+	}
+	return outcomes
+}
+
+// processStream verifies a CSV file a row at a time in bounded memory. Rows
+// are read a window at a time, verified in domain batches and written in
+// input order through a reorder buffer as soon as every earlier row is done.
+// Greylisted addresses are not re-verified, and the name patterns learned
+// are reported without rescoring rows. Each address is verified once,
+// at its first occurrence, while its result is remembered. A resumed run
+// reads the results of finished rows back from the journal as their rows
+// come up. It returns the progress and the number of addresses an
+// interruption left unverified.
+func (p *pipeline) processStream(ctx context.Context, cfg *config.Config, resumed *io.JournalIndex) (*progress, int) {
+	if cfg.InputType != "csv" || cfg.OutputType != "csv" {
+		log.Fatalf("Streaming requires csv input and output, got %s and %s", cfg.InputType, cfg.OutputType)
+	}
+
+	reader, err := io.NewCSVRowReader(cfg.InputFile)
+	if err != nil {
+		log.Fatalf("Error reading input file: %v", err)
+	}
+	defer reader.Close()
+
+	// Find the columns holding the emails to verify from the first rows
+	sample, err := reader.Peek(emailSampleSize)
+	if err != nil {
+		log.Fatalf("Error reading input file: %v", err)
+	}
+	sampleRecords := make([]io.Record, len(sample))
+	for i, row := range sample {
+		sampleRecords[i] = row.Record
+	}
+	columns, err := reader.Schema().EmailColumns(cfg.EmailColumns, sampleRecords)
+	if err != nil {
+		log.Fatalf("Error finding email columns in %s: %v", cfg.InputFile, err)
+	}
+	p.checkLeadColumns(reader.Schema())
+	slots := make(map[int]int, len(columns)) // column -> position among the email columns
+	for k, column := range columns {
+		slots[column] = k
+	}
+
+	writer, err := io.NewCSVRowWriter(cfg.OutputFile, reader.Schema(), columns)
+	if err != nil {
+		log.Fatalf("Error writing results: %v", err)
+	}
+
+	// The total is unknown until the whole file has been read
+	progress := newProgress(0)
+
+	var wg sync.WaitGroup
+	batchesChan := make(chan []task)
+	outcomes := make(chan outcome)
+	outstanding := make(chan struct{}, maxOutstandingRows) // rows read but not yet written
+	shared := newSharedResults()
+
+	// Start workers
+	for i := 0; i < p.numWorkers; i++ {
+		wg.Add(1)
+		go p.worker(ctx, batchesChan, outcomes, &wg, progress)
+	}
+
+	// Read rows, sending each window of addresses to the workers. Addresses
+	// that need no verification, and every address once the pipeline stops,
+	// go straight to the writer, as do repeated addresses whose result is
+	// known.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(batchesChan)
+
+		firstRows := newFirstRows(maxFirstRows)
+		var window []task
+		flush := func() {
+			for _, t := range p.dispatch(ctx, window, batchesChan) {
+				outcomes <- outcome{task: t, result: notCheckedResult(t.email)}
+			}
+			window = window[:0]
+		}
+
+		for {
+			row, err := reader.Next()
+			if err == goio.EOF {
+				break
+			}
+			if err != nil {
+				log.Fatalf("Error reading input file: %v", err)
+			}
+			select {
+			case outstanding <- struct{}{}:
+			default:
+				// The rows held back may be waiting for addresses in the window
+				flush()
+				outstanding <- struct{}{}
+			}
+
+			for k, column := range columns {
+				t := task{row: row, column: column, email: strings.TrimSpace(row.Record.Values[column])}
+				if k == 0 {
+					t.duplicateOf = firstRows.duplicateOf(t)
+				}
+				if t.email == "" {
+					var result verifier.Result
+					if k == 0 {
+						log.Printf("Warning: No email in row %d. Setting to invalid.", row.Index)
+						result.VerificationStatus = "invalid"
+					}
+					outcomes <- outcome{task: t, result: result}
+					continue
+				}
+				result, found, verify := shared.claim(t)
+				if found {
+					outcomes <- outcome{task: t, result: result, shared: true}
+					continue
+				}
+				if !verify {
+					continue
+				}
+				if result, ok := resumed.Result(t.cell()); ok && strings.EqualFold(result.Email, t.email) {
+					progress.update(progressStatus(result))
+					outcomes <- outcome{task: t, result: result}
+					continue
+				}
+				if p.stopped() {
+					outcomes <- outcome{task: t, result: notCheckedResult(t.email)}
+					continue
+				}
+				window = append(window, t)
+			}
+			if len(window) >= streamWindow {
+				flush()
+			}
+		}
+		flush()
+	}()
+
+	// Wait for the reader and all workers to finish
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	// Write rows back in input order once every email column has its
+	// result, leaving out repeated rows if asked
+	buffer := io.NewReorderBuffer(func(row io.Row, result io.RowResult) error {
+		if result.DuplicateOf != 0 && cfg.DropDuplicates {
+			return nil
+		}
+		return writer.Write(row, result)
+	})
+	partial := make(map[int]*io.RowResult) // row index -> results so far
+	remaining := make(map[int]int)         // row index -> email columns without a result
+	notChecked := 0
+	var collect func(o outcome)
+	collect = func(o outcome) {
+		if o.result.VerificationStatus == "not_checked" {
+			notChecked++
+		}
+		if o.email != "" && !o.shared {
+			for _, w := range shared.settle(o.task, o.result) {
+				collect(w)
+			}
+		}
+
+		i := o.row.Index
+		if _, ok := partial[i]; !ok {
+			partial[i] = &io.RowResult{Results: make([]verifier.Result, len(columns))}
+			remaining[i] = len(columns)
+		}
+		partial[i].Results[slots[o.column]] = o.result
+		if slots[o.column] == 0 {
+			partial[i].DuplicateOf = o.duplicateOf
+		}
+		if remaining[i]--; remaining[i] > 0 {
+			return
+		}
+
+		p.checkLead(o.row.Record, partial[i].Results)
+		written, err := buffer.Add(o.row, *partial[i])
+		if err != nil {
+			log.Fatalf("Error writing results: %v", err)
+		}
+		delete(partial, i)
+		delete(remaining, i)
+		for ; written > 0; written-- {
+			<-outstanding
+		}
+	}
+	for o := range outcomes {
+		collect(o)
+	}
+
+	if err := writer.Close(); err != nil {
+		log.Fatalf("Error writing results: %v", err)
+	}
+	if n := p.deferred.Len(); n > 0 {
+		log.Printf("%d greylisted addresses were not re-verified while streaming", n)
+	}
+	return progress, notChecked
+}
+
+// firstRows remembers the first row of each address in the first email
+// column, to report the rows repeating it. It forgets the least recently
+// seen address once it holds more than limit, unless limit is 0.
+type firstRows struct {
+	limit int
+	order *list.List // addresses, most recently seen at the front
+	rows  map[string]*list.Element
+}
+
+// firstRow is the first row of an address
+type firstRow struct {
+	key   string // normalized email
+	index int
+}
+
+func newFirstRows(limit int) *firstRows {
+	return &firstRows{limit: limit, order: list.New(), rows: make(map[string]*list.Element)}
+}
+
+// duplicateOf returns the file row number of the first row whose first
+// email column holds the address of t, or 0 if t's row is the first
+func (f *firstRows) duplicateOf(t task) int {
+	if t.email == "" {
+		return 0
+	}
+	key := verifier.NormalizeEmail(t.email)
+	if elem, ok := f.rows[key]; ok {
+		f.order.MoveToFront(elem)
+		return fileRow(elem.Value.(firstRow).index)
+	}
+	f.rows[key] = f.order.PushFront(firstRow{key: key, index: t.row.Index})
+	if f.limit > 0 && f.order.Len() > f.limit {
+		oldest := f.order.Back()
+		f.order.Remove(oldest)
+		delete(f.rows, oldest.Value.(firstRow).key)
+	}
+	return 0
+}